@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/bridge"
+	"github.com/PhillipMichelsen/Tessera/internal/discovery"
+	"github.com/PhillipMichelsen/Tessera/internal/node"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// parseRoutes builds a static registry from "<mailbox uuid>=<bridge address>" routes.
+func parseRoutes(routes []string) (*bridge.Registry, error) {
+	registry := bridge.NewRegistry()
+	for _, route := range routes {
+		mailbox, address, ok := strings.Cut(route, "=")
+		if !ok || address == "" {
+			return nil, fmt.Errorf("invalid bridge route %q, expected <mailbox uuid>=<bridge address>", route)
+		}
+		mailboxUUID, err := uuid.Parse(mailbox)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mailbox in bridge route %q: %w", route, err)
+		}
+		registry.Register(mailboxUUID, address)
+	}
+	return registry, nil
+}
+
+// resolverChain resolves a mailbox with the first resolver that knows it, so static routes take precedence over
+// discovery.
+type resolverChain []bridge.Resolver
+
+func (c resolverChain) ResolveMailbox(mailboxUUID uuid.UUID) (string, bool) {
+	for _, resolver := range c {
+		if address, ok := resolver.ResolveMailbox(mailboxUUID); ok {
+			return address, true
+		}
+	}
+	return "", false
+}
+
+// newDiscovery creates a cluster discovery in the mode, advertising this node's bridge and discovery URL to the peers.
+func newDiscovery(mode, nodeID, bridgeAddress, discoveryURL string, peers []string, interval time.Duration) (*discovery.ClusterDiscovery, error) {
+	if nodeID == "" {
+		nodeID = bridgeAddress
+	}
+	return discovery.NewClusterDiscovery(discovery.Config{
+		Mode: discovery.Mode(mode),
+		Self: discovery.Peer{
+			NodeID:        nodeID,
+			BridgeAddress: bridgeAddress,
+			DiscoveryURL:  strings.TrimSuffix(discoveryURL, "/"),
+		},
+		Peers:    peers,
+		Interval: interval,
+	})
+}
+
+// startBridge listens for peer nodes on the address and routes the node's sends to mailboxes it does not own
+// through the bridge, resolving their owners with the resolver.
+func startBridge(nodeInst *node.Node, address string, resolver bridge.Resolver) (*bridge.Bridge, error) {
+	messageBridge := bridge.NewBridge(resolver, nodeInst.DeliverMessage, nodeInst.DeadLetterMessage)
+	if err := messageBridge.Listen(address); err != nil {
+		return nil, err
+	}
+	nodeInst.AttachBridge(messageBridge)
+	return messageBridge, nil
+}
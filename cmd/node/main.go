@@ -1,7 +1,13 @@
 package main
 
 import (
-	_ "embed"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/bridge"
+	"github.com/PhillipMichelsen/Tessera/internal/controlplane"
+	"github.com/PhillipMichelsen/Tessera/internal/discovery"
+	"github.com/PhillipMichelsen/Tessera/internal/metrics"
 	"github.com/PhillipMichelsen/Tessera/internal/node"
 	"github.com/PhillipMichelsen/Tessera/internal/worker"
 	"github.com/PhillipMichelsen/Tessera/internal/worker/workers"
@@ -10,23 +16,51 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
-// Embed YAML files. Currently testing.
-//
-//go:embed tasks/task1_create.yaml
-var task1Yaml []byte
-
-//go:embed tasks/task2_start.yaml
-var task2Yaml []byte
-
 func main() {
+	var taskFiles, bridgeRoutes, discoveryPeers stringList
+	flag.Var(&taskFiles, "task", "task YAML file to process at startup, in order (repeatable)")
+	taskDir := flag.String("task-dir", "", "directory of task YAML files to process at startup, in file name order")
+	fromStdin := flag.Bool("stdin", false, "read \"---\" separated task documents from stdin and process them at startup")
+	listenAddress := flag.String("listen", "127.0.0.1:8080", "address the control plane HTTP API listens on")
+	logLevel := flag.String("log-level", "debug", "log level (trace, debug, info, warn, error)")
+	logFormat := flag.String("log-format", "console", "log format (console or json)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "time allowed for workers to drain and stop on shutdown")
+	traceFile := flag.String("trace-file", "", "file the per-edge trace latencies are dumped to as JSON, on shutdown and every -trace-interval")
+	traceInterval := flag.Duration("trace-interval", 0, "interval between trace dumps to -trace-file (0 dumps only on shutdown)")
+	deadLetterCapacity := flag.Int("dead-letter-capacity", node.DefaultDeadLetterCapacity, "number of undeliverable or unhandled messages kept for inspection and replay")
+	bridgeListen := flag.String("bridge-listen", "", "address the message bridge listens on for peer nodes, e.g. 127.0.0.1:9090 (empty disables the bridge)")
+	flag.Var(&bridgeRoutes, "bridge-route", "static route to a mailbox on a peer node, as <mailbox uuid>=<bridge address> (repeatable)")
+	bridgeAdvertise := flag.String("bridge-advertise", "", "bridge address peer nodes reach this node on (defaults to -bridge-listen)")
+	discoveryMode := flag.String("discovery-mode", "", "how mailbox ownership is shared with peer nodes, static or gossip (empty disables discovery; requires -bridge-listen)")
+	flag.Var(&discoveryPeers, "discovery-peer", "discovery URL of a peer node, e.g. http://10.0.0.2:8080, to sync with or seed gossip from (repeatable)")
+	discoveryURL := flag.String("discovery-url", "", "discovery URL peer nodes reach this node's control plane on (defaults to http://<-listen>)")
+	discoveryInterval := flag.Duration("discovery-interval", 0, "interval between discovery sync rounds (0 uses the default)")
+	nodeID := flag.String("node-id", "", "ID of this node in the cluster (defaults to the advertised bridge address)")
+	dryRun := flag.Bool("dry-run", false, "validate the tasks against a fresh node, report any issues and exit")
+	flag.Parse()
+
 	// Set up logging
+	level, err := zerolog.ParseLevel(*logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid log level %q\n", *logLevel)
+		os.Exit(2)
+	}
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
-	log.Logger = log.Output(zerolog.ConsoleWriter{
-		Out:        os.Stderr,
-		TimeFormat: "15:04:05",
-	}).Level(zerolog.DebugLevel)
+	switch *logFormat {
+	case "console":
+		log.Logger = log.Output(zerolog.ConsoleWriter{
+			Out:        os.Stderr,
+			TimeFormat: "15:04:05",
+		}).Level(level)
+	case "json":
+		log.Logger = zerolog.New(os.Stderr).With().Timestamp().Logger().Level(level)
+	default:
+		fmt.Fprintf(os.Stderr, "invalid log format %q\n", *logFormat)
+		os.Exit(2)
+	}
 
 	// Create a new worker factory.
 	workerFactory := worker.AggregateFactories(
@@ -38,37 +72,188 @@ func main() {
 
 	// Create a new node instance.
 	nodeInst := node.NewNode(workerFactory)
+	nodeInst.SetDeadLetterCapacity(*deadLetterCapacity)
+
+	// Route sends to mailboxes on peer nodes through the message bridge, resolving their owners with the static
+	// routes and, if enabled, cluster discovery.
+	var messageBridge *bridge.Bridge
+	var clusterDiscovery *discovery.ClusterDiscovery
+	if *discoveryMode != "" && *bridgeListen == "" {
+		log.Fatal().Msg("Discovery requires -bridge-listen")
+	}
+	if *bridgeListen != "" && !*dryRun {
+		registry, err := parseRoutes(bridgeRoutes)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid bridge routes")
+		}
+		resolver := resolverChain{registry}
+
+		if *discoveryMode != "" {
+			advertised := *bridgeAdvertise
+			if advertised == "" {
+				advertised = *bridgeListen
+			}
+			selfURL := *discoveryURL
+			if selfURL == "" {
+				selfURL = "http://" + *listenAddress
+			}
+			clusterDiscovery, err = newDiscovery(*discoveryMode, *nodeID, advertised, selfURL, discoveryPeers, *discoveryInterval)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Invalid discovery configuration")
+			}
+			resolver = append(resolver, clusterDiscovery)
+		}
+
+		messageBridge, err = startBridge(nodeInst, *bridgeListen, resolver)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to start message bridge")
+		}
+		log.Info().Str("address", messageBridge.Addr()).Int("routes", len(bridgeRoutes)).Msg("Message bridge listening")
+
+		if clusterDiscovery != nil {
+			nodeInst.AttachDiscovery(clusterDiscovery)
+			clusterDiscovery.Start()
+			log.Info().Str("mode", *discoveryMode).Int("peers", len(discoveryPeers)).Msg("Cluster discovery started")
+		}
+	}
 
-	// Define embedded tasks in the desired order.
-	tasksYamlBytes := [][]byte{
-		task1Yaml,
-		task2Yaml,
+	// Load the startup tasks in the order they should run.
+	sources, err := loadTaskSources(taskFiles, *taskDir, *fromStdin)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load tasks")
+	}
+
+	// In dry-run mode, validate every task as one plan and exit without applying anything.
+	if *dryRun {
+		os.Exit(validateTasks(nodeInst, sources))
 	}
 
 	// Process each task in order.
-	for _, yamlBytes := range tasksYamlBytes {
-		log.Info().Msg("Processing new task")
+	for _, source := range sources {
+		log.Info().Str("task", source.name).Msg("Processing task")
 
-		// Parse the task from the embedded YAML.
-		task, err := nodeInst.ParseTask(yamlBytes)
+		task, err := nodeInst.ParseTask(source.yaml)
 		if err != nil {
-			log.Error().Err(err).Msg("Failed to parse task from embedded YAML")
+			log.Error().Err(err).Str("task", source.name).Msg("Failed to parse task")
 			continue
 		}
 
-		// Process the task.
-		if err := nodeInst.ProcessTask(task); err != nil {
-			log.Error().Err(err).Msg("Failed to process task from embedded YAML")
+		if _, err := nodeInst.ProcessTask(task); err != nil {
+			log.Error().Err(err).Str("task", source.name).Msg("Failed to process task")
 			continue
 		}
 
-		log.Info().Msg("Successfully processed embedded task")
+		log.Info().Str("task", source.name).Msg("Successfully processed task")
+	}
+
+	// Start the control plane so tasks can be submitted to the running node.
+	controlPlane := controlplane.NewServer(nodeInst)
+
+	// Serve the node's metrics in the Prometheus text format alongside the control plane.
+	registry := metrics.NewRegistry()
+	registry.Register(nodeInst)
+	controlPlane.Handle("GET /metrics", registry)
+
+	// Serve the discovery sync endpoint peers exchange mailbox tables on.
+	if clusterDiscovery != nil {
+		controlPlane.Handle("POST "+discovery.SyncPath, clusterDiscovery.Handler())
+	}
+
+	if err := controlPlane.Start(*listenAddress); err != nil {
+		log.Fatal().Err(err).Msg("Failed to start control plane")
+	}
+	log.Info().Str("address", controlPlane.Addr()).Msg("Control plane listening")
+
+	// Periodically dump the trace latencies if requested.
+	traceCtx, stopTraceDumps := context.WithCancel(context.Background())
+	if *traceFile != "" && *traceInterval > 0 {
+		go dumpTracesPeriodically(traceCtx, nodeInst, *traceFile, *traceInterval)
 	}
 
 	// Handle graceful shutdown.
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 	<-signals
+	stopTraceDumps()
 
 	log.Info().Msg("Shutting down gracefully...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := controlPlane.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("Failed to shut down control plane")
+	}
+
+	results, err := nodeInst.Shutdown(shutdownCtx)
+	for _, result := range results {
+		event := log.Info()
+		if !result.Clean {
+			event = log.Warn().Str("error", result.Error)
+		}
+		event.Str("worker", result.UUID.String()).
+			Str("type", result.Type).
+			Int("stage", result.Stage).
+			Str("exit_code", result.ExitCode.String()).
+			Bool("clean", result.Clean).
+			Msg("Worker stopped")
+	}
+	if clusterDiscovery != nil {
+		if err := clusterDiscovery.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close cluster discovery")
+		}
+	}
+	if messageBridge != nil {
+		if err := messageBridge.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close message bridge")
+		}
+	}
+	if *traceFile != "" {
+		if err := writeTraces(nodeInst, *traceFile); err != nil {
+			log.Error().Err(err).Str("path", *traceFile).Msg("Failed to dump traces")
+		} else {
+			log.Info().Str("path", *traceFile).Msg("Dumped traces")
+		}
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Node did not shut down before the deadline")
+		os.Exit(1)
+	}
+	log.Info().Msg("Node shut down")
+}
+
+// validateTasks parses and validates the tasks in order, printing every issue found. It returns the process exit code.
+func validateTasks(nodeInst *node.Node, sources []taskSource) int {
+	tasks := make([]node.Task, 0, len(sources))
+	parseFailed := false
+	for _, source := range sources {
+		task, err := nodeInst.ParseTask(source.yaml)
+		if err != nil {
+			fmt.Printf("%s: %v\n", source.name, err)
+			parseFailed = true
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	if parseFailed {
+		return 1
+	}
+
+	validationErr := nodeInst.ValidateTasks(tasks...)
+	if validationErr == nil {
+		fmt.Printf("%d task(s) valid\n", len(tasks))
+		return 0
+	}
+
+	for _, warning := range validationErr.Warnings {
+		fmt.Printf("%s instruction %d (%s): warning: %s\n", sources[warning.Task].name, warning.Instruction, warning.Type, warning.Message)
+	}
+	if !validationErr.HasIssues() {
+		fmt.Printf("%d task(s) valid with %d warning(s)\n", len(tasks), len(validationErr.Warnings))
+		return 0
+	}
+	for _, issue := range validationErr.Issues {
+		fmt.Printf("%s instruction %d (%s): %s\n", sources[issue.Task].name, issue.Instruction, issue.Type, issue.Message)
+	}
+	fmt.Printf("%d issue(s) found\n", len(validationErr.Issues))
+	return 1
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// taskSource is a single task document and where it was read from.
+type taskSource struct {
+	name string
+	yaml []byte
+}
+
+// stringList collects the values of a repeatable flag.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// loadTaskSources reads the task files, then the YAML files of the task directory in name order, then the
+// documents on stdin, returning the tasks in the order they should run.
+func loadTaskSources(taskFiles []string, taskDir string, fromStdin bool) ([]taskSource, error) {
+	var sources []taskSource
+
+	for _, path := range taskFiles {
+		fileSources, err := readTaskFile(path)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, fileSources...)
+	}
+
+	if taskDir != "" {
+		entries, err := os.ReadDir(taskDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read task directory: %w", err)
+		}
+
+		var paths []string
+		for _, entry := range entries {
+			extension := filepath.Ext(entry.Name())
+			if entry.IsDir() || (extension != ".yaml" && extension != ".yml") {
+				continue
+			}
+			paths = append(paths, filepath.Join(taskDir, entry.Name()))
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			fileSources, err := readTaskFile(path)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, fileSources...)
+		}
+	}
+
+	if fromStdin {
+		stdinSources, err := splitTaskDocuments("stdin", os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, stdinSources...)
+	}
+
+	return sources, nil
+}
+
+// readTaskFile reads every task document in a YAML file.
+func readTaskFile(path string) ([]taskSource, error) {
+	yamlBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read task file: %w", err)
+	}
+	return splitTaskDocuments(path, bytes.NewReader(yamlBytes))
+}
+
+// splitTaskDocuments splits a stream of "---" separated YAML documents into one task source per document.
+func splitTaskDocuments(name string, reader io.Reader) ([]taskSource, error) {
+	decoder := yaml.NewDecoder(reader)
+
+	var documents []*yaml.Node
+	for {
+		var document yaml.Node
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode tasks from %s: %w", name, err)
+		}
+		documents = append(documents, &document)
+	}
+
+	sources := make([]taskSource, 0, len(documents))
+	for i, document := range documents {
+		yamlBytes, err := yaml.Marshal(document)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode task %d from %s: %w", i, name, err)
+		}
+
+		sourceName := name
+		if len(documents) > 1 {
+			sourceName = fmt.Sprintf("%s[%d]", name, i)
+		}
+		sources = append(sources, taskSource{name: sourceName, yaml: yamlBytes})
+	}
+
+	return sources, nil
+}
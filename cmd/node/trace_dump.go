@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/node"
+	"github.com/rs/zerolog/log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// traceDump is the JSON document written to the trace file.
+type traceDump struct {
+	WrittenAt time.Time        `json:"written_at"`
+	Edges     []node.TraceEdge `json:"edges"`
+}
+
+// writeTraces writes the node's trace edges to path, replacing the file atomically so readers never see a
+// partial dump.
+func writeTraces(nodeInst *node.Node, path string) error {
+	data, err := json.MarshalIndent(traceDump{WrittenAt: time.Now(), Edges: nodeInst.TraceEdges()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode traces: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create trace file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write trace file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write trace file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace trace file: %w", err)
+	}
+	return nil
+}
+
+// dumpTracesPeriodically writes the node's trace edges to path every interval until ctx is done.
+func dumpTracesPeriodically(ctx context.Context, nodeInst *node.Node, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := writeTraces(nodeInst, path); err != nil {
+				log.Error().Err(err).Str("path", path).Msg("Failed to dump traces")
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/PhillipMichelsen/Tessera/internal/orchestrator"
+	"github.com/PhillipMichelsen/Tessera/internal/pipeline"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// stringList collects the values of a repeatable flag.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func main() {
+	var nodes, pipelines stringList
+	flag.Var(&nodes, "node", "node to orchestrate, as id=control_url (repeatable)")
+	flag.Var(&pipelines, "pipeline", "pipeline spec YAML file to apply (repeatable)")
+	interval := flag.Duration("interval", 10*time.Second, "interval between reconciles")
+	flag.Parse()
+
+	// Set up logging
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = log.Output(zerolog.ConsoleWriter{
+		Out:        os.Stderr,
+		TimeFormat: "15:04:05",
+	}).Level(zerolog.DebugLevel)
+
+	orch := orchestrator.NewOrchestrator(nil)
+
+	for _, nodeFlag := range nodes {
+		id, controlURL, ok := strings.Cut(nodeFlag, "=")
+		if !ok {
+			log.Fatal().Str("node", nodeFlag).Msg("Node must be given as id=control_url")
+		}
+		if err := orch.RegisterNode(id, controlURL); err != nil {
+			log.Fatal().Err(err).Msg("Failed to register node")
+		}
+		log.Info().Str("node", id).Str("url", controlURL).Msg("Registered node")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	for _, path := range pipelines {
+		specYaml, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatal().Err(err).Str("path", path).Msg("Failed to read pipeline spec")
+		}
+
+		spec, err := pipeline.ParseSpec(specYaml)
+		if err != nil {
+			log.Fatal().Err(err).Str("path", path).Msg("Failed to parse pipeline spec")
+		}
+		compiled, err := pipeline.Compile(spec)
+		if err != nil {
+			log.Fatal().Err(err).Str("path", path).Msg("Failed to compile pipeline spec")
+		}
+
+		// Failures are retried by the reconcile loop, so they are not fatal here.
+		if err := orch.Apply(ctx, compiled); err != nil {
+			log.Error().Err(err).Str("pipeline", spec.Name).Msg("Pipeline not fully applied")
+			continue
+		}
+		log.Info().Str("pipeline", spec.Name).Msg("Applied pipeline")
+	}
+
+	log.Info().Dur("interval", *interval).Msg("Reconciling")
+	orch.Run(ctx, *interval)
+
+	log.Info().Msg("Shutting down gracefully...")
+}
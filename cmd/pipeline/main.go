@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/node"
+	"github.com/PhillipMichelsen/Tessera/internal/pipeline"
+	"os"
+)
+
+// Compiles a pipeline spec and prints the create and start tasks it expands to, ready to submit to a node.
+func main() {
+	specPath := flag.String("f", "", "pipeline spec YAML file to compile")
+	stage := flag.String("task", "all", "task to print: create, start, stop or all (create followed by start)")
+	flag.Parse()
+
+	if *specPath == "" {
+		fmt.Fprintln(os.Stderr, "usage: pipeline -f spec.yaml [-task create|start|stop|all]")
+		os.Exit(2)
+	}
+
+	specYaml, err := os.ReadFile(*specPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read pipeline spec: %v\n", err)
+		os.Exit(1)
+	}
+
+	spec, err := pipeline.ParseSpec(specYaml)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	compiled, err := pipeline.Compile(spec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pipeline %s failed to compile:\n%v\n", spec.Name, err)
+		os.Exit(1)
+	}
+
+	var task node.Task
+	switch *stage {
+	case "create":
+		task = compiled.CreateTask()
+	case "start":
+		task = compiled.StartTask()
+	case "stop":
+		task = compiled.StopTask()
+	case "all":
+		task = compiled.CreateTask()
+		task.Instructions = append(task.Instructions, compiled.StartTask().Instructions...)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown task %q\n", *stage)
+		os.Exit(2)
+	}
+
+	taskYaml, err := node.MarshalTask(task)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Print(string(taskYaml))
+}
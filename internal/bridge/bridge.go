@@ -0,0 +1,317 @@
+package bridge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/google/uuid"
+	"net"
+	"sync"
+	"time"
+)
+
+// dialTimeout bounds how long the bridge waits when connecting to a peer node.
+const dialTimeout = 5 * time.Second
+
+// writeTimeout bounds how long a send waits for a peer node to accept a frame.
+const writeTimeout = 5 * time.Second
+
+// deliveryBacklog is the number of messages received on a connection for one mailbox that queue up while an
+// earlier message waits for space in the mailbox.
+const deliveryBacklog = 1024
+
+// DeliverFunc delivers a message received from a peer node into a local mailbox.
+type DeliverFunc func(mailboxUUID uuid.UUID, message worker.Message, block bool) error
+
+// DeadLetterFunc records a message received from a peer node that could not be decoded or delivered.
+type DeadLetterFunc func(mailboxUUID uuid.UUID, message worker.Message, reason error)
+
+// Bridge forwards messages to mailboxes owned by other nodes over TCP, and delivers messages received from
+// other nodes into the local node. Frames are sent as newline-delimited JSON on one connection per peer.
+// Delivery is one-way: failures on the receiving node go to its dead letters and are not returned to the sender.
+type Bridge struct {
+	resolver   Resolver
+	deliver    DeliverFunc
+	deadLetter DeadLetterFunc
+
+	mu       sync.Mutex
+	listener net.Listener
+	peers    map[string]*peerConnection
+	inbound  map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// peerConnection is an outbound connection to a peer node's bridge.
+type peerConnection struct {
+	mu      sync.Mutex
+	conn    net.Conn
+	encoder *json.Encoder
+}
+
+// NewBridge creates a bridge that resolves remote mailboxes with the resolver, hands received messages to deliver
+// and those it fails to decode or deliver to deadLetter.
+func NewBridge(resolver Resolver, deliver DeliverFunc, deadLetter DeadLetterFunc) *Bridge {
+	return &Bridge{
+		resolver:   resolver,
+		deliver:    deliver,
+		deadLetter: deadLetter,
+		peers:      make(map[string]*peerConnection),
+		inbound:    make(map[net.Conn]struct{}),
+	}
+}
+
+// Listen starts accepting connections from peer nodes on the given address, e.g. "127.0.0.1:0".
+func (b *Bridge) Listen(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		_ = listener.Close()
+		return fmt.Errorf("bridge is closed")
+	}
+	b.listener = listener
+	b.mu.Unlock()
+
+	b.wg.Add(1)
+	go b.acceptLoop(listener)
+
+	return nil
+}
+
+// Addr returns the address the bridge is listening on, or an empty string if it is not listening.
+func (b *Bridge) Addr() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.listener == nil {
+		return ""
+	}
+	return b.listener.Addr().String()
+}
+
+// ResolveMailbox returns the address of the node owning the mailbox, as the bridge routes sends to it.
+func (b *Bridge) ResolveMailbox(mailboxUUID uuid.UUID) (string, bool) {
+	return b.resolver.ResolveMailbox(mailboxUUID)
+}
+
+// Send forwards the message to the node owning the destination mailbox. Unless block is set, it fails instead of
+// waiting while another send is writing to the same peer.
+func (b *Bridge) Send(destinationMailboxUUID uuid.UUID, message worker.Message, block bool) error {
+	address, ok := b.resolver.ResolveMailbox(destinationMailboxUUID)
+	if !ok {
+		return fmt.Errorf("no node found owning mailbox %s", destinationMailboxUUID)
+	}
+
+	frame, err := EncodeFrame(destinationMailboxUUID, message, block)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	// A stale connection is only discovered on write, so retry once on a fresh connection.
+	for attempt := 0; attempt < 2; attempt++ {
+		peer, err := b.peer(address)
+		if err != nil {
+			return err
+		}
+
+		if block {
+			peer.mu.Lock()
+		} else if !peer.mu.TryLock() {
+			return fmt.Errorf("connection to %s is busy", address)
+		}
+		err = peer.write(frame)
+		peer.mu.Unlock()
+		if err == nil {
+			return nil
+		}
+
+		b.dropPeer(address, peer)
+		if attempt == 1 {
+			return fmt.Errorf("failed to send message to %s: %w", address, err)
+		}
+	}
+
+	return nil
+}
+
+// Close stops accepting connections, closes every peer connection and waits for inbound readers to exit.
+func (b *Bridge) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+
+	var err error
+	if b.listener != nil {
+		err = b.listener.Close()
+	}
+	for address, peer := range b.peers {
+		_ = peer.conn.Close()
+		delete(b.peers, address)
+	}
+	for conn := range b.inbound {
+		_ = conn.Close()
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+	return err
+}
+
+// peer returns the outbound connection for the address, dialing it if necessary. The dial happens outside the
+// lock, so an unreachable peer does not hold up sends to the others.
+func (b *Bridge) peer(address string) (*peerConnection, error) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil, fmt.Errorf("bridge is closed")
+	}
+	if peer, exists := b.peers[address]; exists {
+		b.mu.Unlock()
+		return peer, nil
+	}
+	b.mu.Unlock()
+
+	conn, err := net.DialTimeout("tcp", address, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to peer %s: %w", address, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// The bridge may have been closed, or another sender may have connected, while dialing.
+	if b.closed {
+		_ = conn.Close()
+		return nil, fmt.Errorf("bridge is closed")
+	}
+	if peer, exists := b.peers[address]; exists {
+		_ = conn.Close()
+		return peer, nil
+	}
+
+	peer := &peerConnection{
+		conn:    conn,
+		encoder: json.NewEncoder(conn),
+	}
+	b.peers[address] = peer
+	return peer, nil
+}
+
+// write encodes the frame onto the connection, failing if the peer does not accept it within the write timeout.
+// The connection lock must be held.
+func (p *peerConnection) write(frame Frame) error {
+	if err := p.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+	return p.encoder.Encode(frame)
+}
+
+// dropPeer closes and forgets a broken outbound connection.
+func (b *Bridge) dropPeer(address string, peer *peerConnection) {
+	b.mu.Lock()
+	if b.peers[address] == peer {
+		delete(b.peers, address)
+	}
+	b.mu.Unlock()
+
+	_ = peer.conn.Close()
+}
+
+// acceptLoop accepts inbound peer connections until the listener is closed.
+func (b *Bridge) acceptLoop(listener net.Listener) {
+	defer b.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				fmt.Printf("bridge failed to accept connection: %v\n", err)
+			}
+			return
+		}
+
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		b.inbound[conn] = struct{}{}
+		b.wg.Add(1)
+		b.mu.Unlock()
+
+		go b.readLoop(conn)
+	}
+}
+
+// delivery is a message received from a peer node, waiting to be delivered.
+type delivery struct {
+	message worker.Message
+	block   bool
+}
+
+// readLoop decodes frames from an inbound connection until it closes, handing each to the delivery queue of its
+// mailbox. Each mailbox's messages are delivered in order by their own goroutine, so the reader never waits for a
+// full mailbox and a slow worker only holds up its own messages. A message that cannot be decoded, or that finds
+// its mailbox's queue full, goes to the dead letters.
+func (b *Bridge) readLoop(conn net.Conn) {
+	defer b.wg.Done()
+
+	deliveries := make(map[uuid.UUID]chan delivery)
+	defer func() {
+		b.mu.Lock()
+		delete(b.inbound, conn)
+		b.mu.Unlock()
+		_ = conn.Close()
+
+		for _, queue := range deliveries {
+			close(queue)
+		}
+	}()
+
+	decoder := json.NewDecoder(conn)
+	for {
+		var frame Frame
+		if err := decoder.Decode(&frame); err != nil {
+			return
+		}
+
+		message, err := DecodeFrame(frame)
+		if err != nil {
+			b.deadLetter(frame.MailboxUUID, message, fmt.Errorf("failed to decode bridged message: %w", err))
+			continue
+		}
+
+		queue, exists := deliveries[frame.MailboxUUID]
+		if !exists {
+			queue = make(chan delivery, deliveryBacklog)
+			deliveries[frame.MailboxUUID] = queue
+			go b.deliverLoop(frame.MailboxUUID, queue)
+		}
+		select {
+		case queue <- delivery{message: message, block: frame.Block}:
+		default:
+			b.deadLetter(frame.MailboxUUID, message, fmt.Errorf("bridge delivery queue of mailbox %s is full", frame.MailboxUUID))
+		}
+	}
+}
+
+// deliverLoop delivers a mailbox's received messages in order until its queue is closed. A block delivery waits for
+// space in the mailbox, so it is not tied to the bridge's lifetime: it ends once the mailbox takes the message or
+// is removed.
+func (b *Bridge) deliverLoop(mailboxUUID uuid.UUID, queue <-chan delivery) {
+	for d := range queue {
+		if err := b.deliver(mailboxUUID, d.message, d.block); err != nil {
+			b.deadLetter(mailboxUUID, d.message, fmt.Errorf("failed to deliver bridged message: %w", err))
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package bridge_test
+
+import (
+	"github.com/PhillipMichelsen/Tessera/internal/bridge"
+	"github.com/PhillipMichelsen/Tessera/internal/models"
+	"github.com/PhillipMichelsen/Tessera/internal/node"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/PhillipMichelsen/Tessera/internal/worker/workers"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+// testNode is a node with a bridge listening on a loopback port.
+type testNode struct {
+	node     *node.Node
+	bridge   *bridge.Bridge
+	services *node.WorkerServices
+}
+
+func newTestNode(t *testing.T, resolver bridge.Resolver) testNode {
+	t.Helper()
+
+	n := node.NewNode(worker.NewFactory())
+	b := bridge.NewBridge(resolver, n.DeliverMessage, n.DeadLetterMessage)
+	if err := b.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = b.Close() })
+	n.AttachBridge(b)
+
+	return testNode{node: n, bridge: b, services: node.NewWorkerServices(n, uuid.New(), "Test")}
+}
+
+// receive waits for the next message on the mailbox channel.
+func receive(t *testing.T, mailbox <-chan any) worker.Message {
+	t.Helper()
+
+	select {
+	case rawMessage := <-mailbox:
+		message, ok := rawMessage.(worker.Message)
+		if !ok {
+			t.Fatalf("received %T, not worker.Message", rawMessage)
+		}
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a bridged message")
+		return worker.Message{}
+	}
+}
+
+func TestBridgeDeliversBetweenTwoNodes(t *testing.T) {
+	registry := bridge.NewRegistry()
+	a := newTestNode(t, registry)
+	b := newTestNode(t, registry)
+
+	mailboxA, mailboxB := uuid.New(), uuid.New()
+	inboxA, err := a.services.CreateMailbox(mailboxA, 10)
+	if err != nil {
+		t.Fatalf("failed to create mailbox on node a: %v", err)
+	}
+	inboxB, err := b.services.CreateMailbox(mailboxB, 10)
+	if err != nil {
+		t.Fatalf("failed to create mailbox on node b: %v", err)
+	}
+	registry.Register(mailboxA, a.bridge.Addr())
+	registry.Register(mailboxB, b.bridge.Addr())
+
+	book := models.OrderBook{Bids: []models.OrderBookEntry{{Price: 1, Quantity: 2}}}
+	if err := a.services.SendMessage(mailboxB, worker.Message{Tag: "to_b", Payload: book}, true); err != nil {
+		t.Fatalf("failed to send from a to b: %v", err)
+	}
+	if err := b.services.SendMessage(mailboxA, worker.Message{Tag: "to_a", Payload: "pong"}, false); err != nil {
+		t.Fatalf("failed to send from b to a: %v", err)
+	}
+
+	atB := receive(t, inboxB)
+	if atB.Tag != "to_b" {
+		t.Errorf("node b received tag %q, want %q", atB.Tag, "to_b")
+	}
+	received, ok := atB.Payload.(models.OrderBook)
+	if !ok || len(received.Bids) != 1 || received.Bids[0] != book.Bids[0] {
+		t.Errorf("node b received payload %#v, want %#v", atB.Payload, book)
+	}
+	if atB.SourceMailbox != mailboxA || atB.Sequence != 1 {
+		t.Errorf("node b received source mailbox %s and sequence %d, want %s and 1", atB.SourceMailbox, atB.Sequence, mailboxA)
+	}
+
+	atA := receive(t, inboxA)
+	if atA.Tag != "to_a" || atA.Payload != "pong" {
+		t.Errorf("node a received %q with payload %#v, want %q with %q", atA.Tag, atA.Payload, "to_a", "pong")
+	}
+}
+
+func TestBridgeSendToUnknownMailboxFails(t *testing.T) {
+	a := newTestNode(t, bridge.NewRegistry())
+
+	if err := a.services.SendMessage(uuid.New(), worker.Message{Tag: "lost", Payload: "x"}, false); err == nil {
+		t.Fatal("expected sending to an unresolved mailbox to fail")
+	}
+}
+
+func TestBridgeFullMailboxDoesNotStallConnection(t *testing.T) {
+	registry := bridge.NewRegistry()
+	a := newTestNode(t, registry)
+	b := newTestNode(t, registry)
+
+	fullMailbox, otherMailbox := uuid.New(), uuid.New()
+	if _, err := b.services.CreateMailbox(fullMailbox, 1); err != nil {
+		t.Fatalf("failed to create mailbox on node b: %v", err)
+	}
+	defer b.services.RemoveMailbox(fullMailbox)
+	otherInbox, err := b.services.CreateMailbox(otherMailbox, 10)
+	if err != nil {
+		t.Fatalf("failed to create mailbox on node b: %v", err)
+	}
+	registry.Register(fullMailbox, b.bridge.Addr())
+	registry.Register(otherMailbox, b.bridge.Addr())
+
+	// The mailbox is never received from, so the last messages wait for space in it.
+	for i := 0; i < 4; i++ {
+		if err := a.services.SendMessage(fullMailbox, worker.Message{Tag: "fill", Payload: "x"}, true); err != nil {
+			t.Fatalf("failed to send to the full mailbox: %v", err)
+		}
+	}
+	if err := a.services.SendMessage(otherMailbox, worker.Message{Tag: "other", Payload: "y"}, true); err != nil {
+		t.Fatalf("failed to send to the other mailbox: %v", err)
+	}
+
+	if message := receive(t, otherInbox); message.Tag != "other" {
+		t.Errorf("received tag %q, want %q", message.Tag, "other")
+	}
+}
+
+func TestBridgeUndeliverableMessageIsDeadLettered(t *testing.T) {
+	registry := bridge.NewRegistry()
+	a := newTestNode(t, registry)
+	b := newTestNode(t, registry)
+
+	// The registry routes the mailbox to node b, which does not have it.
+	missingMailbox := uuid.New()
+	registry.Register(missingMailbox, b.bridge.Addr())
+	if err := a.services.SendMessage(missingMailbox, worker.Message{Tag: "lost", Payload: "x"}, false); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		deadLetters := b.node.DeadLetters()
+		if len(deadLetters) == 1 {
+			if deadLetters[0].Destination != missingMailbox || deadLetters[0].Tag != "lost" {
+				t.Errorf("dead letter for %s tagged %q, want %s tagged %q", deadLetters[0].Destination, deadLetters[0].Tag, missingMailbox, "lost")
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("node b has %d dead letters, want 1", len(deadLetters))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBridgedValidationResolvesDestinations(t *testing.T) {
+	routed := uuid.New()
+	registry := bridge.NewRegistry()
+	registry.Register(routed, "127.0.0.1:1")
+
+	n := node.NewNode(workers.NewPrebuiltStandardWorkersFactory())
+	n.AttachBridge(bridge.NewBridge(registry, n.DeliverMessage, n.DeadLetterMessage))
+
+	tests := []struct {
+		name         string
+		destination  uuid.UUID
+		wantWarnings int
+	}{
+		{"routed destination", routed, 0},
+		{"unknown destination", uuid.New(), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workerUUID := uuid.New()
+			task := node.Task{Instructions: []node.Instruction{
+				{Type: "create_worker", Args: node.CreateWorkerInstructionArgs{WorkerType: "Broadcast", WorkerUUID: workerUUID}},
+				{Type: "start_worker", Args: node.StartWorkerInstructionArgs{WorkerUUID: workerUUID, WorkerRawConfig: []byte(
+					"input_mailbox_uuid: " + uuid.NewString() + "\ntag_destinations:\n  tag: [" + tt.destination.String() + "]\n",
+				)}},
+			}}
+
+			validationErr := n.ValidateTasks(task)
+			if validationErr.HasIssues() {
+				t.Fatalf("validation found issues: %v", validationErr)
+			}
+			warnings := 0
+			if validationErr != nil {
+				warnings = len(validationErr.Warnings)
+			}
+			if warnings != tt.wantWarnings {
+				t.Errorf("validation reported %d warning(s), want %d", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/models"
+	protos "github.com/PhillipMichelsen/Tessera/internal/protos/mexc"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Frame is the wire representation of a message forwarded between nodes.
+// The payload is JSON encoded, with protojson for protobuf messages, and identified by the name it was registered
+// under.
+type Frame struct {
+	MailboxUUID uuid.UUID       `json:"mailbox_uuid"`
+	Block       bool            `json:"block"`
+	Tag         string          `json:"tag"`
+	PayloadType string          `json:"payload_type"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+
+	// Message envelope, see worker.Message.
+	Source        uuid.UUID         `json:"source"`
+	SourceMailbox uuid.UUID         `json:"source_mailbox"`
+	Sequence      uint64            `json:"sequence,omitempty"`
+	Origin        uuid.UUID         `json:"origin"`
+	CreatedAt     time.Time         `json:"created_at"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Trace         []worker.Span     `json:"trace,omitempty"`
+	ReplyTo       uuid.UUID         `json:"reply_to,omitempty"`
+	CorrelationID uuid.UUID         `json:"correlation_id,omitempty"`
+	Priority      worker.Priority   `json:"priority,omitempty"`
+}
+
+// payloadTypes maps payload type names to Go types and back, so payloads survive the trip across the bridge.
+var payloadTypes = struct {
+	sync.RWMutex
+	byName map[string]reflect.Type
+	byType map[reflect.Type]string
+}{
+	byName: make(map[string]reflect.Type),
+	byType: make(map[reflect.Type]string),
+}
+
+func init() {
+	RegisterPayloadType[models.OHLCV]("models.OHLCV")
+	RegisterPayloadType[models.Trade]("models.Trade")
+	RegisterPayloadType[models.BookTicker]("models.BookTicker")
+	RegisterPayloadType[models.OrderBookEntry]("models.OrderBookEntry")
+	RegisterPayloadType[models.OrderBook]("models.OrderBook")
+	RegisterPayloadType[models.SerializedJSON]("models.SerializedJSON")
+	RegisterPayloadType[*protos.PushDataV3ApiWrapper]("mexc.PushDataV3ApiWrapper")
+	RegisterPayloadType[worker.Event]("worker.Event")
+	RegisterPayloadType[string]("string")
+}
+
+// RegisterPayloadType makes payloads of type T transferable across the bridge under the given name.
+// Both nodes must register the same name for the same type.
+func RegisterPayloadType[T any](name string) {
+	payloadType := reflect.TypeFor[T]()
+
+	payloadTypes.Lock()
+	defer payloadTypes.Unlock()
+	payloadTypes.byName[name] = payloadType
+	payloadTypes.byType[payloadType] = name
+}
+
+// EncodeFrame converts a message bound for the given mailbox into a frame.
+func EncodeFrame(mailboxUUID uuid.UUID, message worker.Message, block bool) (Frame, error) {
+	frame := Frame{
+		MailboxUUID:   mailboxUUID,
+		Block:         block,
+		Tag:           message.Tag,
+		Source:        message.Source,
+		SourceMailbox: message.SourceMailbox,
+		Sequence:      message.Sequence,
+		Origin:        message.Origin,
+		CreatedAt:     message.CreatedAt,
+		Headers:       message.Headers,
+		Trace:         message.Trace,
+		ReplyTo:       message.ReplyTo,
+		CorrelationID: message.CorrelationID,
+		Priority:      message.Priority,
+	}
+
+	if message.Payload == nil {
+		return frame, nil
+	}
+
+	payloadTypes.RLock()
+	name, ok := payloadTypes.byType[reflect.TypeOf(message.Payload)]
+	payloadTypes.RUnlock()
+	if !ok {
+		return Frame{}, fmt.Errorf("payload type %T is not registered with the bridge", message.Payload)
+	}
+
+	payload, err := marshalPayload(message.Payload)
+	if err != nil {
+		return Frame{}, fmt.Errorf("failed to marshal payload of type %s: %w", name, err)
+	}
+
+	frame.PayloadType = name
+	frame.Payload = payload
+	return frame, nil
+}
+
+// DecodeFrame converts a frame back into the message it carries. If the payload cannot be decoded, the returned
+// message still carries the envelope, with the payload's raw JSON as a string, so it can be dead lettered.
+func DecodeFrame(frame Frame) (worker.Message, error) {
+	message := worker.Message{
+		Tag:           frame.Tag,
+		Source:        frame.Source,
+		SourceMailbox: frame.SourceMailbox,
+		Sequence:      frame.Sequence,
+		Origin:        frame.Origin,
+		CreatedAt:     frame.CreatedAt,
+		Headers:       frame.Headers,
+		Trace:         frame.Trace,
+		ReplyTo:       frame.ReplyTo,
+		CorrelationID: frame.CorrelationID,
+		Priority:      frame.Priority,
+	}
+
+	if frame.PayloadType == "" {
+		return message, nil
+	}
+
+	payloadTypes.RLock()
+	payloadType, ok := payloadTypes.byName[frame.PayloadType]
+	payloadTypes.RUnlock()
+	if !ok {
+		message.Payload = string(frame.Payload)
+		return message, fmt.Errorf("payload type %s is not registered with the bridge", frame.PayloadType)
+	}
+
+	payload, err := unmarshalPayload(frame.Payload, payloadType)
+	if err != nil {
+		message.Payload = string(frame.Payload)
+		return message, fmt.Errorf("failed to unmarshal payload of type %s: %w", frame.PayloadType, err)
+	}
+
+	message.Payload = payload
+	return message, nil
+}
+
+// protoMessageType is the interface implemented by pointers to protobuf messages.
+var protoMessageType = reflect.TypeFor[proto.Message]()
+
+// marshalPayload encodes a payload as JSON, using protojson for protobuf messages.
+func marshalPayload(payload any) ([]byte, error) {
+	if message, ok := payload.(proto.Message); ok {
+		return protojson.Marshal(message)
+	}
+	return json.Marshal(payload)
+}
+
+// unmarshalPayload decodes a payload of the given type encoded by marshalPayload.
+func unmarshalPayload(data []byte, payloadType reflect.Type) (any, error) {
+	if payloadType.Kind() == reflect.Pointer && payloadType.Implements(protoMessageType) {
+		payload := reflect.New(payloadType.Elem()).Interface().(proto.Message)
+		if err := protojson.Unmarshal(data, payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+	}
+
+	payload := reflect.New(payloadType)
+	if err := json.Unmarshal(data, payload.Interface()); err != nil {
+		return nil, err
+	}
+	return payload.Elem().Interface(), nil
+}
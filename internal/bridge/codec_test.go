@@ -0,0 +1,97 @@
+package bridge
+
+import (
+	"github.com/PhillipMichelsen/Tessera/internal/models"
+	protos "github.com/PhillipMichelsen/Tessera/internal/protos/mexc"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/PhillipMichelsen/Tessera/internal/worker/workers"
+	"github.com/google/uuid"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// shippedPayloads lists samples of the payloads each shipped worker type sends. Workers forwarding the messages
+// they receive as they are send no payloads of their own.
+var shippedPayloads = map[string][]any{
+	"StandardOutput":                          nil,
+	"Broadcast":                               nil,
+	"BinanceSpotWebsocket":                    {models.SerializedJSON{}},
+	"BinanceSpotKlineToOHLCV":                 {models.OHLCV{}},
+	"BinanceSpotBookTickerToBookTicker":       {models.BookTicker{}},
+	"BinanceSpotDepthToOrderBookSnapshot":     {models.OrderBook{}},
+	"BinanceSpotDepthUpdateToOrderBookUpdate": {models.OrderBook{}},
+	"MEXCSpotWebsocket":                       {&protos.PushDataV3ApiWrapper{}},
+	"MEXCSpotBookTickerToBookTicker":          {models.BookTicker{}},
+	"CrossMarketSpotArbitrageStrategy":        {""},
+}
+
+func TestShippedWorkerPayloadsAreRegistered(t *testing.T) {
+	factory := worker.AggregateFactories(
+		workers.NewPrebuiltStandardWorkersFactory(),
+		workers.NewPrebuiltBinanceSpotWorkersFactory(),
+		workers.NewPrebuiltMEXCSpotWorkersFactory(),
+		workers.NewStrategyWorkersFactory(),
+	)
+
+	var workerTypes []string
+	for workerType := range factory.ConfigSchemas() {
+		workerTypes = append(workerTypes, workerType)
+	}
+	sort.Strings(workerTypes)
+
+	for _, workerType := range workerTypes {
+		t.Run(workerType, func(t *testing.T) {
+			payloads, listed := shippedPayloads[workerType]
+			if !listed {
+				t.Fatalf("worker type %s is not listed in shippedPayloads", workerType)
+			}
+
+			payloadTypes.RLock()
+			defer payloadTypes.RUnlock()
+			for _, payload := range payloads {
+				if _, ok := payloadTypes.byType[reflect.TypeOf(payload)]; !ok {
+					t.Errorf("payload type %T is not registered with the bridge", payload)
+				}
+			}
+		})
+	}
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload any
+	}{
+		{"no payload", nil},
+		{"string", "pong"},
+		{"order book", models.OrderBook{Bids: []models.OrderBookEntry{{Price: 1, Quantity: 2}}}},
+		{"serialized JSON", models.SerializedJSON{JSON: `{"a":1}`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := EncodeFrame(uuid.New(), worker.Message{Tag: "tag", Payload: tt.payload}, false)
+			if err != nil {
+				t.Fatalf("failed to encode: %v", err)
+			}
+			message, err := DecodeFrame(frame)
+			if err != nil {
+				t.Fatalf("failed to decode: %v", err)
+			}
+			if message.Tag != "tag" || !reflect.DeepEqual(message.Payload, tt.payload) {
+				t.Errorf("decoded %q with payload %#v, want %q with %#v", message.Tag, message.Payload, "tag", tt.payload)
+			}
+		})
+	}
+}
+
+func TestDecodeFrameKeepsEnvelopeOfUnknownPayload(t *testing.T) {
+	message, err := DecodeFrame(Frame{Tag: "tag", PayloadType: "unknown", Payload: []byte(`{"a":1}`)})
+	if err == nil {
+		t.Fatal("expected decoding an unregistered payload type to fail")
+	}
+	if message.Tag != "tag" || message.Payload != `{"a":1}` {
+		t.Errorf("decoded %q with payload %#v, want the envelope and the raw payload", message.Tag, message.Payload)
+	}
+}
@@ -0,0 +1,47 @@
+package bridge
+
+import (
+	"github.com/google/uuid"
+	"sync"
+)
+
+// Resolver resolves the bridge address of the node owning a mailbox.
+type Resolver interface {
+	ResolveMailbox(mailboxUUID uuid.UUID) (address string, ok bool)
+}
+
+// Registry is a static Resolver mapping mailbox UUIDs to the bridge addresses of the nodes that own them.
+type Registry struct {
+	mu        sync.RWMutex
+	mailboxes map[uuid.UUID]string
+}
+
+// NewRegistry initializes an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		mailboxes: make(map[uuid.UUID]string),
+	}
+}
+
+// Register records that the mailbox is owned by the node whose bridge listens on address.
+func (r *Registry) Register(mailboxUUID uuid.UUID, address string) {
+	r.mu.Lock()
+	r.mailboxes[mailboxUUID] = address
+	r.mu.Unlock()
+}
+
+// Unregister forgets the owner of the mailbox.
+func (r *Registry) Unregister(mailboxUUID uuid.UUID) {
+	r.mu.Lock()
+	delete(r.mailboxes, mailboxUUID)
+	r.mu.Unlock()
+}
+
+// ResolveMailbox returns the bridge address of the node owning the mailbox.
+func (r *Registry) ResolveMailbox(mailboxUUID uuid.UUID) (string, bool) {
+	r.mu.RLock()
+	address, ok := r.mailboxes[mailboxUUID]
+	r.mu.RUnlock()
+
+	return address, ok
+}
@@ -0,0 +1,287 @@
+package controlplane
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PhillipMichelsen/Tessera/internal/node"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxTaskBodyBytes bounds the size of a submitted task document.
+	maxTaskBodyBytes = 1 << 20
+	// eventStreamBuffer is the number of events buffered per streaming client before events are dropped.
+	eventStreamBuffer = 256
+)
+
+// TaskResponse is the JSON body returned after a task has been submitted.
+type TaskResponse struct {
+	Results  []node.InstructionResult `json:"results,omitempty"`
+	Issues   []node.ValidationIssue   `json:"issues,omitempty"`
+	Warnings []node.ValidationIssue   `json:"warnings,omitempty"`
+	Error    string                   `json:"error,omitempty"`
+}
+
+// ClearResponse is the JSON body returned after clearing the dead-letter queue.
+type ClearResponse struct {
+	Cleared int `json:"cleared"`
+}
+
+// ErrorResponse is the JSON body returned when a request cannot be handled.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server exposes a node's control plane over HTTP.
+type Server struct {
+	node       *node.Node
+	mux        *http.ServeMux
+	httpServer *http.Server
+	listener   net.Listener
+}
+
+// NewServer creates a control plane server for the given node and registers its routes.
+func NewServer(n *node.Node) *Server {
+	s := &Server{
+		node: n,
+		mux:  http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("POST /tasks", s.handleSubmitTask)
+	s.mux.HandleFunc("GET /workers", s.handleListWorkers)
+	s.mux.HandleFunc("GET /workers/{uuid}", s.handleGetWorker)
+	s.mux.HandleFunc("GET /events", s.handleStreamEvents)
+	s.mux.HandleFunc("GET /traces", s.handleListTraces)
+	s.mux.HandleFunc("GET /topics", s.handleListTopics)
+	s.mux.HandleFunc("GET /deadletters", s.handleListDeadLetters)
+	s.mux.HandleFunc("POST /deadletters/replay", s.handleReplayDeadLetters)
+	s.mux.HandleFunc("DELETE /deadletters", s.handleClearDeadLetters)
+	s.mux.HandleFunc("GET /schemas", s.handleListSchemas)
+	s.mux.HandleFunc("GET /schemas/{type}", s.handleGetSchema)
+
+	return s
+}
+
+// Handler returns the HTTP handler serving the control plane routes.
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Handle registers an additional handler on the control plane, e.g. a discovery sync endpoint.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start listens on the given address and serves the control plane in the background.
+func (s *Server) Start(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
+	s.listener = listener
+	s.httpServer = &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("control plane server stopped: %v\n", err)
+		}
+	}()
+
+	return nil
+}
+
+// Addr returns the address the server is listening on, or an empty string if it has not been started.
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Shutdown gracefully stops the server.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.httpServer == nil {
+		return nil
+	}
+	return s.httpServer.Shutdown(ctx)
+}
+
+// handleSubmitTask parses a YAML task from the request body, processes it and reports per-instruction results.
+// With ?dry_run=true the task is only validated and any issues are reported without applying it.
+func (s *Server) handleSubmitTask(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxTaskBodyBytes))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("failed to read task body: %w", err))
+		return
+	}
+
+	task, err := s.node.ParseTask(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("failed to parse task: %w", err))
+		return
+	}
+
+	if r.URL.Query().Get("dry_run") == "true" {
+		response := TaskResponse{}
+		status := http.StatusOK
+		if validationErr := s.node.ValidateTasks(task); validationErr != nil {
+			response.Issues = validationErr.Issues
+			response.Warnings = validationErr.Warnings
+			if validationErr.HasIssues() {
+				response.Error = validationErr.Error()
+				status = http.StatusUnprocessableEntity
+			}
+		}
+		writeJSON(w, status, response)
+		return
+	}
+
+	results, err := s.node.ProcessTask(task)
+	response := TaskResponse{Results: results}
+	status := http.StatusOK
+	if err != nil {
+		response.Error = err.Error()
+		status = http.StatusUnprocessableEntity
+
+		var validationErr *node.ValidationError
+		if errors.As(err, &validationErr) {
+			response.Issues = validationErr.Issues
+			response.Warnings = validationErr.Warnings
+		}
+	}
+
+	writeJSON(w, status, response)
+}
+
+// handleListWorkers reports the type, status and owned mailboxes of every worker on the node.
+func (s *Server) handleListWorkers(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.node.ListWorkers())
+}
+
+// handleListTraces reports the latency summary of every traced edge.
+func (s *Server) handleListTraces(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.node.TraceEdges())
+}
+
+// handleListTopics reports every topic on the node and its subscribers.
+func (s *Server) handleListTopics(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.node.Topics())
+}
+
+// handleListDeadLetters reports the node's queued dead letters, oldest first.
+func (s *Server) handleListDeadLetters(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.node.DeadLetters())
+}
+
+// handleReplayDeadLetters sends dead letters to their destinations again: those given by ?id=, which may be
+// repeated, or every queued dead letter. It reports the outcome of each replay.
+func (s *Server) handleReplayDeadLetters(w http.ResponseWriter, r *http.Request) {
+	var ids []uint64
+	for _, value := range r.URL.Query()["id"] {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid dead letter id %q: %w", value, err))
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	writeJSON(w, http.StatusOK, s.node.ReplayDeadLetters(ids...))
+}
+
+// handleClearDeadLetters drops every queued dead letter.
+func (s *Server) handleClearDeadLetters(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, ClearResponse{Cleared: s.node.ClearDeadLetters()})
+}
+
+// handleListSchemas reports the JSON Schema of the config of every worker type, keyed by worker type.
+func (s *Server) handleListSchemas(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.node.ConfigSchemas())
+}
+
+// handleGetSchema reports the JSON Schema of the config of a single worker type.
+func (s *Server) handleGetSchema(w http.ResponseWriter, r *http.Request) {
+	workerType := r.PathValue("type")
+	schema, exists := s.node.ConfigSchemas()[workerType]
+	if !exists {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no config schema registered for worker type %s", workerType))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, schema)
+}
+
+// handleGetWorker reports the status of a single worker.
+func (s *Server) handleGetWorker(w http.ResponseWriter, r *http.Request) {
+	workerUUID, err := uuid.Parse(r.PathValue("uuid"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid worker uuid: %w", err))
+		return
+	}
+
+	info, err := s.node.GetWorker(workerUUID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, info)
+}
+
+// handleStreamEvents streams node events to the client as newline-delimited JSON until the client disconnects.
+func (s *Server) handleStreamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+
+	events, cancel := s.node.SubscribeEvents(eventStreamBuffer)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := encoder.Encode(event); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeJSON encodes the value as the JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		fmt.Printf("failed to encode control plane response: %v\n", err)
+	}
+}
+
+// writeError writes an ErrorResponse with the given status code.
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
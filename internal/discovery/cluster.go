@@ -0,0 +1,401 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mode selects how a ClusterDiscovery spreads mailbox ownership between nodes.
+type Mode string
+
+const (
+	// StaticMode pushes every change to a fixed list of peers and periodically resynchronizes with all of them.
+	StaticMode Mode = "static"
+	// GossipMode periodically exchanges tables with a few random known peers, learning new peers as it goes.
+	GossipMode Mode = "gossip"
+)
+
+const (
+	// SyncPath is the path, relative to a peer's discovery URL, that tables are exchanged on.
+	SyncPath = "/discovery/sync"
+
+	defaultInterval     = 2 * time.Second
+	defaultFanout       = 2
+	defaultTombstoneTTL = 5 * time.Minute
+	requestTimeout      = 5 * time.Second
+	maxSyncBodySize     = 16 << 20
+)
+
+// Config configures a ClusterDiscovery.
+// Peers holds the discovery URLs of the static peers, or the seeds to start gossiping with.
+// TombstoneTTL is how long a withdrawal is kept and spread before it is forgotten; it should comfortably exceed the
+// time a change takes to reach every node.
+type Config struct {
+	Mode         Mode
+	Self         Peer
+	Peers        []string
+	Interval     time.Duration
+	Fanout       int
+	TombstoneTTL time.Duration
+	HTTPClient   *http.Client
+}
+
+// syncState is the body exchanged between peers on SyncPath.
+type syncState struct {
+	From    Peer    `json:"from"`
+	Peers   []Peer  `json:"peers"`
+	Entries []Entry `json:"entries"`
+}
+
+// ClusterDiscovery is a Discovery that exchanges mailbox ownership tables with peer nodes over HTTP.
+// Its Handler must be served at the node's discovery URL for peers to reach it.
+type ClusterDiscovery struct {
+	config Config
+
+	mu      sync.RWMutex
+	entries map[uuid.UUID]Entry
+	peers   map[string]Peer // keyed by discovery URL
+	version int64
+
+	// push coalesces the local changes waiting to be pushed to peers in static mode.
+	push   chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewClusterDiscovery validates the configuration and creates a ClusterDiscovery. Call Start to begin syncing.
+func NewClusterDiscovery(config Config) (*ClusterDiscovery, error) {
+	switch config.Mode {
+	case StaticMode, GossipMode:
+	default:
+		return nil, fmt.Errorf("unknown discovery mode %q", config.Mode)
+	}
+	if config.Self.NodeID == "" {
+		return nil, fmt.Errorf("node id is required")
+	}
+	if config.Self.BridgeAddress == "" {
+		return nil, fmt.Errorf("bridge address is required")
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	if config.Fanout <= 0 {
+		config.Fanout = defaultFanout
+	}
+	if config.TombstoneTTL <= 0 {
+		config.TombstoneTTL = defaultTombstoneTTL
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: requestTimeout}
+	}
+
+	d := &ClusterDiscovery{
+		config:  config,
+		entries: make(map[uuid.UUID]Entry),
+		peers:   make(map[string]Peer),
+		push:    make(chan struct{}, 1),
+	}
+	for _, peerURL := range config.Peers {
+		peerURL = strings.TrimSuffix(peerURL, "/")
+		d.peers[peerURL] = Peer{DiscoveryURL: peerURL}
+	}
+
+	return d, nil
+}
+
+// Start begins periodically synchronizing with peers, and pushing local changes to them in static mode, in the
+// background.
+func (d *ClusterDiscovery) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		ticker := time.NewTicker(d.config.Interval)
+		defer ticker.Stop()
+
+		d.syncRound(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				d.pruneTombstones()
+				d.syncRound(ctx)
+			case <-d.push:
+				d.pushAll(ctx)
+			}
+		}
+	}()
+}
+
+// Close stops background synchronization.
+func (d *ClusterDiscovery) Close() error {
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.wg.Wait()
+	return nil
+}
+
+// Announce records the mailbox as owned by this node. In static mode the change is pushed to peers by the Start loop.
+func (d *ClusterDiscovery) Announce(mailboxUUID uuid.UUID) {
+	d.mu.Lock()
+	d.record(mailboxUUID, false)
+	d.mu.Unlock()
+
+	d.signalPush()
+}
+
+// Withdraw records that this node no longer owns the mailbox, if it is owned by this node.
+func (d *ClusterDiscovery) Withdraw(mailboxUUID uuid.UUID) {
+	d.mu.Lock()
+	entry, ok := d.entries[mailboxUUID]
+	if !ok || entry.Withdrawn || entry.Owner.NodeID != d.config.Self.NodeID {
+		d.mu.Unlock()
+		return
+	}
+	d.record(mailboxUUID, true)
+	d.mu.Unlock()
+
+	d.signalPush()
+}
+
+// ResolveMailbox returns the bridge address of the node owning the mailbox.
+func (d *ClusterDiscovery) ResolveMailbox(mailboxUUID uuid.UUID) (string, bool) {
+	d.mu.RLock()
+	entry, ok := d.entries[mailboxUUID]
+	d.mu.RUnlock()
+
+	if !ok || entry.Withdrawn {
+		return "", false
+	}
+	return entry.Owner.BridgeAddress, true
+}
+
+// Entries returns a copy of the current mailbox table, including tombstones.
+func (d *ClusterDiscovery) Entries() []Entry {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	entries := make([]Entry, 0, len(d.entries))
+	for _, entry := range d.entries {
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// Peers returns the peers currently known to this node.
+func (d *ClusterDiscovery) Peers() []Peer {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	peers := make([]Peer, 0, len(d.peers))
+	for _, peer := range d.peers {
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+// Handler returns the HTTP handler peers synchronize with. It must be served at SyncPath.
+func (d *ClusterDiscovery) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var remote syncState
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSyncBodySize)).Decode(&remote); err != nil {
+			http.Error(w, fmt.Sprintf("failed to decode sync state: %v", err), http.StatusBadRequest)
+			return
+		}
+		d.merge(remote)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(d.state()); err != nil {
+			fmt.Printf("discovery failed to encode sync state: %v\n", err)
+		}
+	})
+}
+
+// record versions a local announcement or withdrawal into the table. d.mu must be held.
+func (d *ClusterDiscovery) record(mailboxUUID uuid.UUID, withdrawn bool) {
+	// Versions are wall-clock based so that they keep increasing across node restarts.
+	d.version = max(d.version+1, time.Now().UnixNano())
+	d.entries[mailboxUUID] = Entry{
+		MailboxUUID: mailboxUUID,
+		Owner:       d.config.Self,
+		Version:     d.version,
+		Withdrawn:   withdrawn,
+	}
+}
+
+// expired reports whether the entry is a tombstone older than the tombstone TTL. Tombstones age by their version,
+// which is the owner's wall clock at withdrawal, so every node forgets them at about the same time and peers that
+// have not yet pruned one cannot resurrect it.
+func (d *ClusterDiscovery) expired(entry Entry) bool {
+	return entry.Withdrawn && time.Since(time.Unix(0, entry.Version)) >= d.config.TombstoneTTL
+}
+
+// signalPush asks the Start loop to push the table to peers in static mode. Signals sent while a push is pending
+// are coalesced into it.
+func (d *ClusterDiscovery) signalPush() {
+	if d.config.Mode != StaticMode {
+		return
+	}
+	select {
+	case d.push <- struct{}{}:
+	default:
+	}
+}
+
+// pruneTombstones forgets withdrawals older than the tombstone TTL.
+func (d *ClusterDiscovery) pruneTombstones() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for mailboxUUID, entry := range d.entries {
+		if d.expired(entry) {
+			delete(d.entries, mailboxUUID)
+		}
+	}
+}
+
+// state returns this node's view of the cluster.
+func (d *ClusterDiscovery) state() syncState {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	state := syncState{
+		From:    d.config.Self,
+		Peers:   make([]Peer, 0, len(d.peers)),
+		Entries: make([]Entry, 0, len(d.entries)),
+	}
+	for _, peer := range d.peers {
+		state.Peers = append(state.Peers, peer)
+	}
+	for _, entry := range d.entries {
+		state.Entries = append(state.Entries, entry)
+	}
+	return state
+}
+
+// merge folds a peer's state into the local table. In gossip mode, peers the remote knows about are learned.
+func (d *ClusterDiscovery) merge(remote syncState) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, entry := range remote.Entries {
+		existing, exists := d.entries[entry.MailboxUUID]
+		switch {
+		case exists && !entry.newer(existing):
+		case d.expired(entry):
+			// The withdrawal is still newer than what is known, but too old to keep spreading.
+			delete(d.entries, entry.MailboxUUID)
+		default:
+			d.entries[entry.MailboxUUID] = entry
+		}
+	}
+
+	learn := func(peer Peer) {
+		if peer.DiscoveryURL == "" || peer.DiscoveryURL == d.config.Self.DiscoveryURL {
+			return
+		}
+		peerURL := strings.TrimSuffix(peer.DiscoveryURL, "/")
+		if _, known := d.peers[peerURL]; known || d.config.Mode == GossipMode {
+			peer.DiscoveryURL = peerURL
+			d.peers[peerURL] = peer
+		}
+	}
+
+	learn(remote.From)
+	if d.config.Mode == GossipMode {
+		for _, peer := range remote.Peers {
+			learn(peer)
+		}
+	}
+}
+
+// syncRound performs one round of synchronization according to the mode.
+func (d *ClusterDiscovery) syncRound(ctx context.Context) {
+	if d.config.Mode == StaticMode {
+		d.pushAll(ctx)
+		return
+	}
+
+	peerURLs := d.peerURLs()
+	rand.Shuffle(len(peerURLs), func(i, j int) {
+		peerURLs[i], peerURLs[j] = peerURLs[j], peerURLs[i]
+	})
+	if len(peerURLs) > d.config.Fanout {
+		peerURLs = peerURLs[:d.config.Fanout]
+	}
+	for _, peerURL := range peerURLs {
+		d.exchange(ctx, peerURL)
+	}
+}
+
+// pushAll exchanges state with every known peer.
+func (d *ClusterDiscovery) pushAll(ctx context.Context) {
+	for _, peerURL := range d.peerURLs() {
+		d.exchange(ctx, peerURL)
+	}
+}
+
+// peerURLs returns the discovery URLs of every known peer.
+func (d *ClusterDiscovery) peerURLs() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	peerURLs := make([]string, 0, len(d.peers))
+	for peerURL := range d.peers {
+		peerURLs = append(peerURLs, peerURL)
+	}
+	return peerURLs
+}
+
+// exchange sends the local state to a peer and merges the state it responds with.
+func (d *ClusterDiscovery) exchange(ctx context.Context, peerURL string) {
+	body, err := json.Marshal(d.state())
+	if err != nil {
+		fmt.Printf("discovery failed to encode sync state: %v\n", err)
+		return
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, peerURL+SyncPath, bytes.NewReader(body))
+	if err != nil {
+		fmt.Printf("discovery failed to build sync request for %s: %v\n", peerURL, err)
+		return
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := d.config.HTTPClient.Do(request)
+	if err != nil {
+		// Unreachable peers are expected while the cluster starts up; they are retried next round.
+		return
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		fmt.Printf("discovery sync with %s failed with status %s\n", peerURL, response.Status)
+		return
+	}
+
+	var remote syncState
+	if err := json.NewDecoder(response.Body).Decode(&remote); err != nil {
+		fmt.Printf("discovery failed to decode sync state from %s: %v\n", peerURL, err)
+		return
+	}
+	d.merge(remote)
+}
@@ -0,0 +1,41 @@
+package discovery
+
+import (
+	"github.com/google/uuid"
+)
+
+// Discovery tracks which node owns which mailbox across the cluster.
+// Announce and Withdraw are called from the node's mailbox observer and must not block on the network.
+// ResolveMailbox satisfies bridge.Resolver, so a Discovery can route messages for a bridge directly.
+type Discovery interface {
+	Announce(mailboxUUID uuid.UUID)
+	Withdraw(mailboxUUID uuid.UUID)
+	ResolveMailbox(mailboxUUID uuid.UUID) (address string, ok bool)
+	Close() error
+}
+
+// Peer identifies a node in the cluster by its ID, the address of its message bridge and the base URL its
+// discovery endpoint is served under.
+type Peer struct {
+	NodeID        string `json:"node_id"`
+	BridgeAddress string `json:"bridge_address"`
+	DiscoveryURL  string `json:"discovery_url"`
+}
+
+// Entry records the ownership of a mailbox. Entries are versioned by their owner so that the most recent
+// announcement or withdrawal wins when tables are merged. Withdrawn entries are kept as tombstones
+// until they expire.
+type Entry struct {
+	MailboxUUID uuid.UUID `json:"mailbox_uuid"`
+	Owner       Peer      `json:"owner"`
+	Version     int64     `json:"version"`
+	Withdrawn   bool      `json:"withdrawn"`
+}
+
+// newer reports whether the entry should replace the existing one.
+func (e Entry) newer(existing Entry) bool {
+	if e.Version != existing.Version {
+		return e.Version > existing.Version
+	}
+	return e.Owner.NodeID > existing.Owner.NodeID
+}
@@ -0,0 +1,64 @@
+package discovery
+
+import (
+	"github.com/google/uuid"
+	"sync"
+)
+
+// LocalDirectory is an in-process mailbox directory shared by LocalDiscovery instances.
+// It stands in for a real discovery service when several nodes run in one process, e.g. in tests.
+type LocalDirectory struct {
+	mu        sync.RWMutex
+	mailboxes map[uuid.UUID]string
+}
+
+// NewLocalDirectory initializes an empty directory.
+func NewLocalDirectory() *LocalDirectory {
+	return &LocalDirectory{
+		mailboxes: make(map[uuid.UUID]string),
+	}
+}
+
+// Join returns a Discovery for a node whose bridge listens on bridgeAddress.
+func (d *LocalDirectory) Join(bridgeAddress string) *LocalDiscovery {
+	return &LocalDiscovery{
+		directory:     d,
+		bridgeAddress: bridgeAddress,
+	}
+}
+
+// LocalDiscovery is a Discovery backed by a shared LocalDirectory.
+type LocalDiscovery struct {
+	directory     *LocalDirectory
+	bridgeAddress string
+}
+
+// Announce records the mailbox as owned by this node.
+func (l *LocalDiscovery) Announce(mailboxUUID uuid.UUID) {
+	l.directory.mu.Lock()
+	l.directory.mailboxes[mailboxUUID] = l.bridgeAddress
+	l.directory.mu.Unlock()
+}
+
+// Withdraw forgets the mailbox if it is owned by this node.
+func (l *LocalDiscovery) Withdraw(mailboxUUID uuid.UUID) {
+	l.directory.mu.Lock()
+	if l.directory.mailboxes[mailboxUUID] == l.bridgeAddress {
+		delete(l.directory.mailboxes, mailboxUUID)
+	}
+	l.directory.mu.Unlock()
+}
+
+// ResolveMailbox returns the bridge address of the node owning the mailbox.
+func (l *LocalDiscovery) ResolveMailbox(mailboxUUID uuid.UUID) (string, bool) {
+	l.directory.mu.RLock()
+	address, ok := l.directory.mailboxes[mailboxUUID]
+	l.directory.mu.RUnlock()
+
+	return address, ok
+}
+
+// Close is a no-op for LocalDiscovery.
+func (l *LocalDiscovery) Close() error {
+	return nil
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// LatencyBuckets are histogram bounds, in seconds, suited to in-process message latencies.
+var LatencyBuckets = []float64{
+	0.000001, 0.0000025, 0.000005, 0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005,
+	0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1,
+}
+
+// Histogram counts observations into buckets with fixed upper bounds. It is safe for concurrent use.
+type Histogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// HistogramSnapshot is a point-in-time copy of a histogram. Counts are cumulative, matching Bounds.
+type HistogramSnapshot struct {
+	Bounds []float64
+	Counts []uint64
+	Count  uint64
+	Sum    float64
+}
+
+// NewHistogram creates a histogram with the given bucket upper bounds.
+func NewHistogram(bounds []float64) *Histogram {
+	sorted := make([]float64, len(bounds))
+	copy(sorted, bounds)
+	sort.Float64s(sorted)
+
+	return &Histogram{
+		bounds: sorted,
+		counts: make([]uint64, len(sorted)),
+	}
+}
+
+// Observe records a value.
+func (h *Histogram) Observe(value float64) {
+	index := sort.SearchFloat64s(h.bounds, value)
+
+	h.mu.Lock()
+	if index < len(h.counts) {
+		h.counts[index]++
+	}
+	h.count++
+	h.sum += value
+	h.mu.Unlock()
+}
+
+// ObserveDuration records a duration in seconds.
+func (h *Histogram) ObserveDuration(duration time.Duration) {
+	h.Observe(duration.Seconds())
+}
+
+// Snapshot returns a copy of the histogram with cumulative bucket counts.
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshot := HistogramSnapshot{
+		Bounds: h.bounds,
+		Counts: make([]uint64, len(h.counts)),
+		Count:  h.count,
+		Sum:    h.sum,
+	}
+	var cumulative uint64
+	for i, count := range h.counts {
+		cumulative += count
+		snapshot.Counts[i] = cumulative
+	}
+	return snapshot
+}
+
+// Mean returns the mean of the observations, or zero if there are none.
+func (s HistogramSnapshot) Mean() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
+
+// Quantile estimates the q-quantile of the observations by interpolating within the bucket holding it.
+// Observations above the highest bound are reported as the highest bound.
+func (s HistogramSnapshot) Quantile(q float64) float64 {
+	if s.Count == 0 || len(s.Bounds) == 0 {
+		return 0
+	}
+
+	rank := q * float64(s.Count)
+	lowerBound := 0.0
+	var lowerCount uint64
+	for i, bound := range s.Bounds {
+		if float64(s.Counts[i]) >= rank {
+			inBucket := s.Counts[i] - lowerCount
+			if inBucket == 0 {
+				return bound
+			}
+			return lowerBound + (bound-lowerBound)*(rank-float64(lowerCount))/float64(inBucket)
+		}
+		lowerBound, lowerCount = bound, s.Counts[i]
+	}
+	return s.Bounds[len(s.Bounds)-1]
+}
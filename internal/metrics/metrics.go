@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MetricType is the type of a metric family in the Prometheus text format.
+type MetricType string
+
+const (
+	CounterType   MetricType = "counter"
+	GaugeType     MetricType = "gauge"
+	HistogramType MetricType = "histogram"
+)
+
+// Collector writes its current metrics when the registry is scraped.
+type Collector interface {
+	Collect(w *Writer)
+}
+
+// CollectorFunc adapts a function to the Collector interface.
+type CollectorFunc func(w *Writer)
+
+func (f CollectorFunc) Collect(w *Writer) {
+	f(w)
+}
+
+// Registry serves the metrics of its collectors in the Prometheus text exposition format.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a collector, which is called on every scrape.
+func (r *Registry) Register(collector Collector) {
+	r.mu.Lock()
+	r.collectors = append(r.collectors, collector)
+	r.mu.Unlock()
+}
+
+// ServeHTTP writes the metrics of every collector.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	r.mu.Lock()
+	collectors := make([]Collector, len(r.collectors))
+	copy(collectors, r.collectors)
+	r.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	buffered := bufio.NewWriter(w)
+	writer := &Writer{out: buffered}
+	for _, collector := range collectors {
+		collector.Collect(writer)
+	}
+	_ = buffered.Flush()
+}
+
+// Writer writes metric families in the Prometheus text exposition format. Every sample of a family must be written
+// directly after the family's header.
+type Writer struct {
+	out *bufio.Writer
+}
+
+// Header starts a metric family.
+func (w *Writer) Header(name string, help string, metricType MetricType) {
+	fmt.Fprintf(w.out, "# HELP %s %s\n", name, escapeHelp(help))
+	fmt.Fprintf(w.out, "# TYPE %s %s\n", name, metricType)
+}
+
+// Sample writes a single sample. Labels are given as alternating names and values.
+func (w *Writer) Sample(name string, value float64, labels ...string) {
+	w.out.WriteString(name)
+	w.writeLabels(labels, "", "")
+	w.out.WriteByte(' ')
+	w.out.WriteString(formatValue(value))
+	w.out.WriteByte('\n')
+}
+
+// Histogram writes the bucket, sum and count samples of a histogram snapshot.
+func (w *Writer) Histogram(name string, snapshot HistogramSnapshot, labels ...string) {
+	for i, bound := range snapshot.Bounds {
+		w.out.WriteString(name + "_bucket")
+		w.writeLabels(labels, "le", formatValue(bound))
+		w.out.WriteString(" " + strconv.FormatUint(snapshot.Counts[i], 10) + "\n")
+	}
+	w.out.WriteString(name + "_bucket")
+	w.writeLabels(labels, "le", "+Inf")
+	w.out.WriteString(" " + strconv.FormatUint(snapshot.Count, 10) + "\n")
+
+	w.Sample(name+"_sum", snapshot.Sum, labels...)
+	w.Sample(name+"_count", float64(snapshot.Count), labels...)
+}
+
+// writeLabels writes the label set, followed by an extra label if extraName is not empty.
+func (w *Writer) writeLabels(labels []string, extraName string, extraValue string) {
+	if len(labels) < 2 && extraName == "" {
+		return
+	}
+
+	w.out.WriteByte('{')
+	first := true
+	for i := 0; i+1 < len(labels); i += 2 {
+		if !first {
+			w.out.WriteByte(',')
+		}
+		first = false
+		w.out.WriteString(labels[i] + `="` + escapeLabelValue(labels[i+1]) + `"`)
+	}
+	if extraName != "" {
+		if !first {
+			w.out.WriteByte(',')
+		}
+		w.out.WriteString(extraName + `="` + escapeLabelValue(extraValue) + `"`)
+	}
+	w.out.WriteByte('}')
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+var (
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
@@ -0,0 +1,158 @@
+package node
+
+import (
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// channelMailbox is a mailbox backed by a buffered channel, for the overflow policies a channel applies on its own
+// and mailboxes without a control lane. If no hook is set, the worker receives from the buffered channel itself;
+// otherwise a pump goroutine applies the hook to each message on its way to the worker.
+type channelMailbox struct {
+	mu      sync.RWMutex // Held for reading by pushes and for writing by close, so no push sends on a closed buffer.
+	buffer  chan any
+	options worker.MailboxOptions
+	closed  atomic.Bool
+	holding atomic.Bool
+	closing chan struct{} // Closed when the mailbox is removed.
+
+	// Counters exported as metrics.
+	pushed   atomic.Uint64
+	dropped  atomic.Uint64
+	rejected atomic.Uint64
+
+	// received, if set, is applied to each message as it leaves the buffer.
+	received func(message any) any
+}
+
+// newChannelMailbox creates a mailbox holding up to capacity messages, or one message if capacity is not positive.
+func newChannelMailbox(capacity int, options worker.MailboxOptions) *channelMailbox {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &channelMailbox{
+		buffer:  make(chan any, capacity),
+		options: options,
+		closing: make(chan struct{}),
+	}
+}
+
+// usesChannel reports whether a queue mailbox with the options can be a channel mailbox.
+func usesChannel(options worker.MailboxOptions) bool {
+	if options.ControlCapacity > 0 {
+		return false
+	}
+	switch options.Overflow {
+	case worker.OverflowReject, worker.OverflowBlock, worker.OverflowDropNewest:
+		return true
+	default:
+		return false
+	}
+}
+
+// push queues a message, applying the overflow policy if the mailbox is full. Under the default policy, block
+// waits for space without a limit instead of failing.
+func (c *channelMailbox) push(message worker.Message, block bool) error {
+	if message.Priority == worker.PriorityControl {
+		c.rejected.Add(1)
+		return errNoControlLane
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed.Load() {
+		return errMailboxClosed
+	}
+	select {
+	case c.buffer <- message:
+		c.pushed.Add(1)
+		return nil
+	default:
+	}
+
+	var deadline <-chan time.Time
+	switch c.options.Overflow {
+	case worker.OverflowDropNewest:
+		c.pushed.Add(1)
+		c.dropped.Add(1)
+		return nil
+	case worker.OverflowBlock:
+		if c.options.BlockTimeout > 0 {
+			timer := time.NewTimer(c.options.BlockTimeout)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+	default:
+		if !block {
+			c.rejected.Add(1)
+			return errMailboxFull
+		}
+	}
+
+	select {
+	case c.buffer <- message:
+		c.pushed.Add(1)
+		return nil
+	case <-c.closing:
+		return errMailboxClosed
+	case <-deadline:
+		c.rejected.Add(1)
+		return errMailboxTimeout
+	}
+}
+
+// run applies the hook to the buffered messages and delivers them to the worker until the mailbox is removed, then
+// closes the worker's channel.
+func (c *channelMailbox) run(out chan<- any, done func()) {
+	defer done()
+	defer close(out)
+
+	for message := range c.buffer {
+		c.holding.Store(true)
+		message = c.received(message)
+		select {
+		case out <- message:
+		case <-c.closing:
+			return
+		}
+		c.holding.Store(false)
+	}
+}
+
+// length returns the number of messages not yet taken by the worker.
+func (c *channelMailbox) length() int {
+	depth := len(c.buffer)
+	if c.holding.Load() {
+		depth++
+	}
+	return depth
+}
+
+// stats returns the mailbox's counters and current depth.
+func (c *channelMailbox) stats() MailboxStats {
+	return MailboxStats{
+		Capacity: cap(c.buffer),
+		Depth:    c.length(),
+		Overflow: c.options.Overflow,
+		Backend:  worker.BackendQueue,
+		Pushed:   c.pushed.Load(),
+		Dropped:  c.dropped.Load(),
+		Rejected: c.rejected.Load(),
+	}
+}
+
+// close closes the buffer once no push is sending to it. A worker receiving from the buffer itself still receives
+// the messages left in it. Pushes after close fail instead of panicking.
+func (c *channelMailbox) close() {
+	if !c.closed.CompareAndSwap(false, true) {
+		return
+	}
+	close(c.closing)
+
+	c.mu.Lock()
+	close(c.buffer)
+	c.mu.Unlock()
+}
@@ -0,0 +1,260 @@
+package node
+
+import (
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/metrics"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+// DefaultDeadLetterCapacity is the number of dead letters kept by a node unless configured otherwise.
+const DefaultDeadLetterCapacity = 1000
+
+// DeadLetter is a message that could not be delivered, or that its receiving worker could not handle, with the
+// reason and the mailbox it was meant for.
+type DeadLetter struct {
+	ID          uint64    `json:"id"`
+	Time        time.Time `json:"time"`
+	Reason      string    `json:"reason"`
+	Destination uuid.UUID `json:"destination"`
+	Worker      uuid.UUID `json:"worker"`
+	WorkerType  string    `json:"worker_type"`
+	Tag         string    `json:"tag"`
+	PayloadType string    `json:"payload_type,omitempty"`
+	Payload     string    `json:"payload,omitempty"`
+	Source      uuid.UUID `json:"source"`
+	Sequence    uint64    `json:"sequence,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// deadLetterEntry is a queued dead letter. The message is kept as is and only formatted when the dead letters are
+// listed, keeping failed sends cheap.
+type deadLetterEntry struct {
+	id          uint64
+	time        time.Time
+	reason      error
+	destination uuid.UUID
+	worker      uuid.UUID
+	workerType  string
+	message     worker.Message
+}
+
+// deadLetter formats the entry for inspection.
+func (e deadLetterEntry) deadLetter() DeadLetter {
+	deadLetter := DeadLetter{
+		ID:          e.id,
+		Time:        e.time,
+		Reason:      e.reason.Error(),
+		Destination: e.destination,
+		Worker:      e.worker,
+		WorkerType:  e.workerType,
+		Tag:         e.message.Tag,
+		Source:      e.message.Source,
+		Sequence:    e.message.Sequence,
+		CreatedAt:   e.message.CreatedAt,
+	}
+	if e.message.Payload != nil {
+		deadLetter.PayloadType = fmt.Sprintf("%T", e.message.Payload)
+		deadLetter.Payload = fmt.Sprintf("%v", e.message.Payload)
+	}
+	return deadLetter
+}
+
+// DeadLetterReplay is the outcome of replaying a dead letter to its destination.
+type DeadLetterReplay struct {
+	ID    uint64 `json:"id"`
+	Error string `json:"error,omitempty"`
+}
+
+// deadLetterQueue is a bounded ring of dead letters. Once full, the oldest dead letter is evicted for each new one.
+type deadLetterQueue struct {
+	mu      sync.Mutex
+	ring    []deadLetterEntry
+	start   int
+	count   int
+	nextID  uint64
+	total   uint64
+	evicted uint64
+}
+
+func newDeadLetterQueue(capacity int) *deadLetterQueue {
+	q := &deadLetterQueue{}
+	q.reset(capacity)
+	return q
+}
+
+// reset empties the queue and resizes it to hold up to capacity dead letters, or one if capacity is not positive.
+func (q *deadLetterQueue) reset(capacity int) {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.ring = make([]deadLetterEntry, capacity)
+	q.start, q.count = 0, 0
+}
+
+// add queues a dead letter, assigning its ID.
+func (q *deadLetterQueue) add(entry deadLetterEntry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.nextID++
+	q.total++
+	entry.id = q.nextID
+	if q.count == len(q.ring) {
+		q.ring[q.start] = entry
+		q.start = (q.start + 1) % len(q.ring)
+		q.evicted++
+		return
+	}
+	q.ring[(q.start+q.count)%len(q.ring)] = entry
+	q.count++
+}
+
+// list returns the queued dead letters, oldest first.
+func (q *deadLetterQueue) list() []deadLetterEntry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries := make([]deadLetterEntry, q.count)
+	for i := range entries {
+		entries[i] = q.ring[(q.start+i)%len(q.ring)]
+	}
+	return entries
+}
+
+// remove drops the dead letters with the given IDs, keeping the others in order.
+func (q *deadLetterQueue) remove(ids map[uint64]bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	kept := 0
+	for i := 0; i < q.count; i++ {
+		entry := q.ring[(q.start+i)%len(q.ring)]
+		if ids[entry.id] {
+			continue
+		}
+		q.ring[(q.start+kept)%len(q.ring)] = entry
+		kept++
+	}
+	for i := kept; i < q.count; i++ {
+		q.ring[(q.start+i)%len(q.ring)] = deadLetterEntry{}
+	}
+	q.count = kept
+}
+
+// clear drops every queued dead letter, returning how many there were.
+func (q *deadLetterQueue) clear() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	cleared := q.count
+	for i := range q.ring {
+		q.ring[i] = deadLetterEntry{}
+	}
+	q.start, q.count = 0, 0
+	return cleared
+}
+
+// counters returns the number of queued dead letters, and how many have been added and evicted.
+func (q *deadLetterQueue) counters() (queued int, total uint64, evicted uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.count, q.total, q.evicted
+}
+
+// SetDeadLetterCapacity empties the node's dead-letter queue and resizes it to hold up to capacity dead letters.
+// Intended to be called before any workers are started.
+func (n *Node) SetDeadLetterCapacity(capacity int) {
+	n.deadLetters.reset(capacity)
+}
+
+// deadLetter records a message that could not be delivered to, or handled at, the destination mailbox.
+func (n *Node) deadLetter(workerUUID uuid.UUID, workerType string, destinationMailboxUUID uuid.UUID, message worker.Message, reason error) {
+	// A span left open by the failed send would otherwise be completed by a replay, counting the time spent as a
+	// dead letter as latency.
+	if last := len(message.Trace) - 1; last >= 0 && message.Trace[last].ReceivedAt.IsZero() {
+		message.Trace = message.Trace[:last]
+	}
+
+	n.deadLetters.add(deadLetterEntry{
+		time:        time.Now(),
+		reason:      reason,
+		destination: destinationMailboxUUID,
+		worker:      workerUUID,
+		workerType:  workerType,
+		message:     message,
+	})
+}
+
+// DeadLetterMessage records a message received from another node that could not be delivered to the destination
+// mailbox, attributing it to its source worker.
+func (n *Node) DeadLetterMessage(destinationMailboxUUID uuid.UUID, message worker.Message, reason error) {
+	n.deadLetter(message.Source, "", destinationMailboxUUID, message, reason)
+}
+
+// DeadLetters returns the queued dead letters, oldest first.
+func (n *Node) DeadLetters() []DeadLetter {
+	entries := n.deadLetters.list()
+	deadLetters := make([]DeadLetter, len(entries))
+	for i, entry := range entries {
+		deadLetters[i] = entry.deadLetter()
+	}
+	return deadLetters
+}
+
+// ReplayDeadLetters sends the dead letters with the given IDs, or every dead letter if none are given, to their
+// destinations again. Replayed dead letters leave the queue; those that fail again stay queued.
+func (n *Node) ReplayDeadLetters(ids ...uint64) []DeadLetterReplay {
+	queued := n.deadLetters.list()
+	byID := make(map[uint64]deadLetterEntry, len(queued))
+	for _, entry := range queued {
+		byID[entry.id] = entry
+	}
+	if len(ids) == 0 {
+		for _, entry := range queued {
+			ids = append(ids, entry.id)
+		}
+	}
+
+	results := make([]DeadLetterReplay, 0, len(ids))
+	replayed := make(map[uint64]bool)
+	for _, id := range ids {
+		entry, exists := byID[id]
+		if !exists {
+			results = append(results, DeadLetterReplay{ID: id, Error: fmt.Sprintf("dead letter %d not found", id)})
+			continue
+		}
+
+		if err := n.sendMessage(entry.destination, entry.message, false); err != nil {
+			results = append(results, DeadLetterReplay{ID: id, Error: err.Error()})
+			continue
+		}
+		replayed[id] = true
+		results = append(results, DeadLetterReplay{ID: id})
+	}
+
+	n.deadLetters.remove(replayed)
+	return results
+}
+
+// ClearDeadLetters drops every queued dead letter, returning how many there were.
+func (n *Node) ClearDeadLetters() int {
+	return n.deadLetters.clear()
+}
+
+// collectDeadLetters writes the size of the dead-letter queue and its counters.
+func (n *Node) collectDeadLetters(w *metrics.Writer) {
+	queued, total, evicted := n.deadLetters.counters()
+
+	w.Header("tessera_dead_letters", "Dead letters queued on the node.", metrics.GaugeType)
+	w.Sample("tessera_dead_letters", float64(queued))
+	w.Header("tessera_dead_letters_total", "Messages added to the dead-letter queue.", metrics.CounterType)
+	w.Sample("tessera_dead_letters_total", float64(total))
+	w.Header("tessera_dead_letters_evicted_total", "Dead letters evicted to make room for newer ones.", metrics.CounterType)
+	w.Sample("tessera_dead_letters_evicted_total", float64(evicted))
+}
@@ -0,0 +1,106 @@
+package node
+
+import (
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/google/uuid"
+	"reflect"
+	"testing"
+)
+
+func TestReplayDeadLetters(t *testing.T) {
+	tests := []struct {
+		name        string
+		createFirst bool     // Whether the first destination exists when replaying.
+		ids         []uint64 // IDs to replay, or every dead letter if empty.
+		want        []DeadLetterReplay
+		wantQueued  []uint64
+	}{
+		{
+			name:        "replays a dead letter by ID",
+			createFirst: true,
+			ids:         []uint64{1},
+			want:        []DeadLetterReplay{{ID: 1}},
+			wantQueued:  []uint64{2},
+		},
+		{
+			name:        "keeps dead letters that fail again",
+			createFirst: true,
+			want:        []DeadLetterReplay{{ID: 1}, {ID: 2, Error: "failed"}},
+			wantQueued:  []uint64{2},
+		},
+		{
+			name:       "reports unknown IDs",
+			ids:        []uint64{7},
+			want:       []DeadLetterReplay{{ID: 7, Error: "dead letter 7 not found"}},
+			wantQueued: []uint64{1, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNode(t)
+			services := NewWorkerServices(n, uuid.New(), "Test")
+
+			first, second := uuid.New(), uuid.New()
+			for _, destination := range []uuid.UUID{first, second} {
+				if err := services.SendMessage(destination, worker.Message{Tag: "tag"}, false); err == nil {
+					t.Fatalf("sending to missing mailbox %s succeeded", destination)
+				}
+			}
+
+			var mailbox <-chan any
+			if tt.createFirst {
+				var err error
+				if mailbox, err = n.dispatcher.CreateMailbox(first, 1); err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { n.dispatcher.RemoveMailbox(first) })
+			}
+
+			results := n.ReplayDeadLetters(tt.ids...)
+			// Errors of sends that fail again vary with the destination, so only their presence is compared.
+			for i := range results {
+				if results[i].Error != "" && tt.want[i].Error == "failed" {
+					results[i].Error = "failed"
+				}
+			}
+			if !reflect.DeepEqual(results, tt.want) {
+				t.Errorf("replay returned %+v, want %+v", results, tt.want)
+			}
+
+			var queued []uint64
+			for _, deadLetter := range n.DeadLetters() {
+				queued = append(queued, deadLetter.ID)
+			}
+			if !reflect.DeepEqual(queued, tt.wantQueued) {
+				t.Errorf("dead letters %v left queued, want %v", queued, tt.wantQueued)
+			}
+
+			if tt.createFirst {
+				if message := (<-mailbox).(worker.Message); message.Tag != "tag" {
+					t.Errorf("replayed message has tag %q, want %q", message.Tag, "tag")
+				}
+			}
+		})
+	}
+}
+
+func TestDeadLetterCapacityEvictsOldest(t *testing.T) {
+	n := newTestNode(t)
+	n.SetDeadLetterCapacity(2)
+
+	for i := 0; i < 3; i++ {
+		n.DeadLetterMessage(uuid.New(), worker.Message{}, errMailboxNotFound)
+	}
+
+	var queued []uint64
+	for _, deadLetter := range n.DeadLetters() {
+		queued = append(queued, deadLetter.ID)
+	}
+	if want := []uint64{2, 3}; !reflect.DeepEqual(queued, want) {
+		t.Errorf("dead letters %v queued, want %v", queued, want)
+	}
+	if _, total, evicted := n.deadLetters.counters(); total != 3 || evicted != 1 {
+		t.Errorf("%d added and %d evicted, want 3 and 1", total, evicted)
+	}
+}
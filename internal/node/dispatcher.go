@@ -2,121 +2,263 @@ package node
 
 import (
 	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
 	"github.com/google/uuid"
 	"sync"
-	"sync/atomic"
 	"time"
 )
 
 // Dispatcher manages mailboxes and their processing.
 // For each mailbox it creates, it spawns a goroutine that
 // continuously dequeues messages and passes them to the receiver function.
+// Mailboxes are kept in a sync.Map, so sends look them up without locking and short-lived mailboxes are cheap to
+// create and remove.
 type Dispatcher struct {
-	mu         sync.RWMutex
-	mailboxes  map[uuid.UUID]chan any
-	receivers  map[uuid.UUID]func(message any)
-	wg         sync.WaitGroup
-	pushCounts map[uuid.UUID]*int64
+	mu        sync.Mutex // Serializes changes to the mailbox map and observers.
+	mailboxes sync.Map   // uuid.UUID to mailboxQueue
+	wg        sync.WaitGroup
+	observers []MailboxObserver
+
+	// traces, if set, completes the trace spans of messages as they leave a mailbox's queue.
+	traces *traceCollector
+}
+
+// mailboxQueue is a mailbox backend.
+type mailboxQueue interface {
+	push(message worker.Message, block bool) error
+	length() int
+	stats() MailboxStats
+	close()
+}
+
+// MailboxStats is a point-in-time snapshot of a mailbox's depth and counters. Depth counts the messages of both
+// lanes and ControlDepth those of the control lane. Pushed counts accepted messages, including those later dropped
+// by the overflow policy, and Rejected counts pushes that failed because the mailbox or its control lane was full.
+type MailboxStats struct {
+	UUID         uuid.UUID
+	Capacity     int
+	Depth        int
+	ControlDepth int
+	Overflow     worker.OverflowPolicy
+	Backend      worker.MailboxBackend
+	Pushed       uint64
+	Dropped      uint64
+	Rejected     uint64
 }
 
+// MailboxObserver is notified after a mailbox is created or removed. It is called without the dispatcher lock held.
+type MailboxObserver func(created bool, mailboxUUID uuid.UUID)
+
 // NewDispatcher initializes the dispatcher.
 func NewDispatcher() *Dispatcher {
-	return &Dispatcher{
-		mailboxes: make(map[uuid.UUID]chan any),
-		receivers: make(map[uuid.UUID]func(message any)),
-	}
+	return &Dispatcher{}
 }
 
 // CreateMailbox registers a worker's mailbox with its message handler.
-// It creates a new mailbox and spawns a processing goroutine.
-func (d *Dispatcher) CreateMailbox(mailboxUUID uuid.UUID, bufferSize int) (<-chan any, error) {
-	if d.mailboxes[mailboxUUID] != nil {
-		return nil, fmt.Errorf("mailbox %v already exists", mailboxUUID)
+// It creates a new mailbox holding up to bufferSize messages and spawns a processing goroutine.
+func (d *Dispatcher) CreateMailbox(mailboxUUID uuid.UUID, bufferSize int, opts ...worker.MailboxOption) (<-chan any, error) {
+	mb, err := d.createMailbox(mailboxUUID, bufferSize, false, opts...)
+	if err != nil {
+		return nil, err
 	}
+	return d.channel(mb), nil
+}
+
+// CreateInbox creates a mailbox like CreateMailbox, returning an inbox to receive from it. Ring mailboxes are
+// received from directly, without a processing goroutine.
+func (d *Dispatcher) CreateInbox(mailboxUUID uuid.UUID, bufferSize int, opts ...worker.MailboxOption) (worker.Inbox, error) {
+	mb, err := d.createMailbox(mailboxUUID, bufferSize, false, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return d.inbox(mb), nil
+}
 
-	mailbox := make(chan any, bufferSize)
+// createMailbox creates and registers a mailbox. Ephemeral mailboxes, which live only for a single exchange, are
+// neither traced, as every mailbox traced gets its own latency histograms, nor reported to the mailbox observers, so
+// they are not announced to discovery. They must be removed with removeMailbox.
+func (d *Dispatcher) createMailbox(mailboxUUID uuid.UUID, bufferSize int, ephemeral bool, opts ...worker.MailboxOption) (mailboxQueue, error) {
+	options := worker.NewMailboxOptions(opts...)
+	if err := options.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid options for mailbox %v: %w", mailboxUUID, err)
+	}
+
+	traces := d.traces
+	if ephemeral {
+		traces = nil
+	}
+
+	var mb mailboxQueue
+	switch {
+	case options.Backend == worker.BackendRing:
+		ring := newRingMailbox(bufferSize, options)
+		if traces != nil {
+			ring.received = func(message *worker.Message) {
+				traces.finish(mailboxUUID, message, time.Now())
+			}
+		}
+		mb = ring
+	case usesChannel(options):
+		channel := newChannelMailbox(bufferSize, options)
+		if traces != nil {
+			channel.received = func(message any) any {
+				return traces.received(mailboxUUID, message, time.Now())
+			}
+		}
+		mb = channel
+	default:
+		queue := newMailbox(bufferSize, options)
+		if traces != nil {
+			queue.received = func(message any) any {
+				return traces.received(mailboxUUID, message, time.Now())
+			}
+		}
+		mb = queue
+	}
 
 	d.mu.Lock()
-	defer d.mu.Unlock()
-	d.mailboxes[mailboxUUID] = mailbox
-	if d.pushCounts == nil {
-		d.pushCounts = make(map[uuid.UUID]*int64)
+	if _, exists := d.mailboxes.LoadOrStore(mailboxUUID, mb); exists {
+		d.mu.Unlock()
+		return nil, fmt.Errorf("mailbox %v already exists", mailboxUUID)
+	}
+	observers := d.observers
+	d.mu.Unlock()
+
+	if !ephemeral {
+		for _, observer := range observers {
+			observer(true, mailboxUUID)
+		}
 	}
 
-	d.pushCounts[mailboxUUID] = new(int64)
-	d.wg.Add(1)
+	return mb, nil
+}
+
+// channel starts the mailbox's processing goroutine, returning the channel it delivers messages to. A channel mailbox
+// without a hook is received from directly, without a processing goroutine.
+func (d *Dispatcher) channel(mb mailboxQueue) <-chan any {
+	switch mb := mb.(type) {
+	case *channelMailbox:
+		if mb.received == nil {
+			return mb.buffer
+		}
+		out := make(chan any)
+		d.wg.Add(1)
+		go mb.run(out, d.wg.Done)
+		return out
+	case *ringMailbox:
+		out := make(chan any)
+		d.wg.Add(1)
+		go mb.run(out, d.wg.Done)
+		return out
+	case *mailbox:
+		d.wg.Add(1)
+		go mb.run(d.wg.Done)
+		return mb.out
+	default:
+		panic(fmt.Sprintf("unknown mailbox backend %T", mb))
+	}
+}
 
-	return mailbox, nil
+// inbox returns an inbox receiving from the mailbox.
+func (d *Dispatcher) inbox(mb mailboxQueue) worker.Inbox {
+	if ring, ok := mb.(*ringMailbox); ok {
+		return ring
+	}
+	return channelInbox(d.channel(mb))
 }
 
 // RemoveMailbox unregisters a worker's mailbox.
 // It closes the mailbox so that its processing goroutine can exit.
 func (d *Dispatcher) RemoveMailbox(mailboxUUID uuid.UUID) {
+	d.removeMailbox(mailboxUUID, false)
+}
+
+// removeMailbox unregisters a mailbox created by createMailbox with the same ephemeral flag.
+func (d *Dispatcher) removeMailbox(mailboxUUID uuid.UUID, ephemeral bool) {
 	d.mu.Lock()
-	mailbox, exists := d.mailboxes[mailboxUUID]
+	mb, exists := d.mailboxes.LoadAndDelete(mailboxUUID)
 	if exists {
-		delete(d.mailboxes, mailboxUUID)
-		delete(d.receivers, mailboxUUID)
-		close(mailbox)
+		mb.(mailboxQueue).close()
 	}
+	observers := d.observers
 	d.mu.Unlock()
+
+	if !exists || ephemeral {
+		return
+	}
+	// The mailbox's latencies go with it; a mailbox created again with the same UUID starts afresh.
+	if d.traces != nil {
+		d.traces.forget(mailboxUUID)
+	}
+	for _, observer := range observers {
+		observer(false, mailboxUUID)
+	}
 }
 
-// PushMessage queues a message for delivery to the destination worker.
-func (d *Dispatcher) PushMessage(destinationMailboxUUID uuid.UUID, message any) error {
-	d.mu.RLock()
-	mailbox, exists := d.mailboxes[destinationMailboxUUID]
-	counter, countExists := d.pushCounts[destinationMailboxUUID]
-	d.mu.RUnlock()
+// AddMailboxObserver registers an observer for mailbox creation and removal.
+func (d *Dispatcher) AddMailboxObserver(observer MailboxObserver) {
+	d.mu.Lock()
+	d.observers = append(d.observers, observer)
+	d.mu.Unlock()
+}
 
-	if !exists || !countExists {
-		return fmt.Errorf("mailbox %v does not exist", destinationMailboxUUID)
-	}
+// ListMailboxes returns the UUIDs of every mailbox currently registered.
+func (d *Dispatcher) ListMailboxes() []uuid.UUID {
+	var mailboxUUIDs []uuid.UUID
+	d.mailboxes.Range(func(key, _ any) bool {
+		mailboxUUIDs = append(mailboxUUIDs, key.(uuid.UUID))
+		return true
+	})
+	return mailboxUUIDs
+}
 
-	select {
-	case mailbox <- message:
-		atomic.AddInt64(counter, 1)
-		return nil
-	default:
-		return fmt.Errorf("mailbox %v is full", destinationMailboxUUID)
-	}
+// PushMessage queues a message for delivery to the destination worker, applying the mailbox's overflow policy
+// if it is full.
+func (d *Dispatcher) PushMessage(destinationMailboxUUID uuid.UUID, message worker.Message) error {
+	return d.push(destinationMailboxUUID, message, false)
 }
 
-func (d *Dispatcher) PushMessageBlocking(destinationMailboxUUID uuid.UUID, message any) error {
-	d.mu.RLock()
-	mailbox, exists := d.mailboxes[destinationMailboxUUID]
-	counter, countExists := d.pushCounts[destinationMailboxUUID]
-	d.mu.RUnlock()
+// PushMessageBlocking queues a message like PushMessage, but waits for space in a full mailbox under the default
+// overflow policy.
+func (d *Dispatcher) PushMessageBlocking(destinationMailboxUUID uuid.UUID, message worker.Message) error {
+	return d.push(destinationMailboxUUID, message, true)
+}
 
-	if !exists || !countExists {
-		return fmt.Errorf("mailbox %v does not exist", destinationMailboxUUID)
+func (d *Dispatcher) push(destinationMailboxUUID uuid.UUID, message worker.Message, block bool) error {
+	mb, exists := d.lookup(destinationMailboxUUID)
+	if !exists {
+		return fmt.Errorf("mailbox %v %w", destinationMailboxUUID, errMailboxNotFound)
 	}
 
-	mailbox <- message
-	atomic.AddInt64(counter, 1)
+	if err := mb.push(message, block); err != nil {
+		return fmt.Errorf("mailbox %v %w", destinationMailboxUUID, err)
+	}
 	return nil
 }
 
+// lookup returns the registered mailbox with the UUID.
+func (d *Dispatcher) lookup(mailboxUUID uuid.UUID) (mailboxQueue, bool) {
+	mb, exists := d.mailboxes.Load(mailboxUUID)
+	if !exists {
+		return nil, false
+	}
+	return mb.(mailboxQueue), true
+}
+
 // CheckMailboxExists checks if a mailbox exists.
 func (d *Dispatcher) CheckMailboxExists(mailboxUUID uuid.UUID) bool {
-	d.mu.RLock()
-	_, exists := d.mailboxes[mailboxUUID]
-	d.mu.RUnlock()
-
+	_, exists := d.mailboxes.Load(mailboxUUID)
 	return exists
 }
 
 // GetMailboxLength returns the number of messages in a worker's mailbox.
 func (d *Dispatcher) GetMailboxLength(mailboxUUID uuid.UUID) int {
-	d.mu.RLock()
-	mailbox, exists := d.mailboxes[mailboxUUID]
-	d.mu.RUnlock()
-
+	mb, exists := d.lookup(mailboxUUID)
 	if !exists {
 		return 0
 	}
 
-	return len(mailbox)
+	return mb.length()
 }
 
 // Wait blocks until all mailbox processing goroutines have exited. Used in graceful shutdown.
@@ -124,18 +266,14 @@ func (d *Dispatcher) Wait() {
 	d.wg.Wait()
 }
 
-func (d *Dispatcher) LogPushRates() {
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		d.mu.RLock()
-		for _, counter := range d.pushCounts {
-			// atomically retrieve and reset the counter.
-			count := atomic.SwapInt64(counter, 0)
-			fmt.Printf("%d, ", count)
-		}
-		fmt.Println()
-		d.mu.RUnlock()
-	}
+// MailboxStats returns the depth and counters of every mailbox currently registered.
+func (d *Dispatcher) MailboxStats() []MailboxStats {
+	var stats []MailboxStats
+	d.mailboxes.Range(func(key, value any) bool {
+		mailboxStats := value.(mailboxQueue).stats()
+		mailboxStats.UUID = key.(uuid.UUID)
+		stats = append(stats, mailboxStats)
+		return true
+	})
+	return stats
 }
@@ -0,0 +1,49 @@
+package node
+
+import (
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/google/uuid"
+	"sync"
+)
+
+// EventBus fans node lifecycle events out to subscribers.
+// Handlers are called synchronously by Publish and must not block or call back into the node.
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers map[uuid.UUID]func(event worker.Event)
+}
+
+// NewEventBus initializes an empty event bus.
+func NewEventBus() *EventBus {
+	return &EventBus{
+		handlers: make(map[uuid.UUID]func(event worker.Event)),
+	}
+}
+
+// Subscribe registers a handler and returns its subscription ID.
+func (b *EventBus) Subscribe(handler func(event worker.Event)) uuid.UUID {
+	subscriptionID := uuid.New()
+
+	b.mu.Lock()
+	b.handlers[subscriptionID] = handler
+	b.mu.Unlock()
+
+	return subscriptionID
+}
+
+// Unsubscribe removes a previously registered handler.
+func (b *EventBus) Unsubscribe(subscriptionID uuid.UUID) {
+	b.mu.Lock()
+	delete(b.handlers, subscriptionID)
+	b.mu.Unlock()
+}
+
+// Publish delivers the event to every subscribed handler.
+func (b *EventBus) Publish(event worker.Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, handler := range b.handlers {
+		handler(event)
+	}
+}
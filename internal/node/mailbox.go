@@ -0,0 +1,344 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"sync"
+	"time"
+)
+
+var (
+	errMailboxFull     = errors.New("is full")
+	errMailboxClosed   = errors.New("was removed")
+	errMailboxTimeout  = errors.New("stayed full until the block timeout")
+	errMailboxNotFound = errors.New("does not exist")
+	errControlLaneFull = errors.New("control lane is full")
+	errNoControlLane   = errors.New("has no control lane")
+)
+
+// mailbox is a bounded queue of messages that applies its overflow policy when a message arrives while it is full,
+// for the policies and control lane a channel mailbox cannot provide. Control messages are queued in a separate
+// lane. Its pump goroutine hands the queued messages to the owning worker
+// through an unbuffered channel, control messages first and each lane in order, and closes the channel once the
+// mailbox is removed.
+type mailbox struct {
+	mu       sync.Mutex
+	queue    []any
+	control  []any
+	capacity int
+	options  worker.MailboxOptions
+	holding  bool
+	closed   bool
+	waiting  int // Producers waiting for space.
+
+	// Counters exported as metrics.
+	pushed   uint64
+	dropped  uint64
+	rejected uint64
+
+	ready   chan struct{} // Signalled when a message is queued.
+	space   chan struct{} // Signalled when a message leaves the queue while producers wait.
+	closing chan struct{} // Closed when the mailbox is removed.
+	out     chan any
+
+	// received, if set, is applied to each message as it leaves the queue.
+	received func(message any) any
+}
+
+// newMailbox creates a mailbox holding up to capacity messages, or one message if capacity is not positive.
+func newMailbox(capacity int, options worker.MailboxOptions) *mailbox {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &mailbox{
+		queue:    make([]any, 0, capacity),
+		capacity: capacity,
+		options:  options,
+		ready:    make(chan struct{}, 1),
+		space:    make(chan struct{}, 1),
+		closing:  make(chan struct{}),
+		out:      make(chan any),
+	}
+}
+
+// push queues a message, applying the overflow policy if the mailbox is full. Under the default policy, block
+// waits for space without a limit instead of failing.
+func (m *mailbox) push(message worker.Message, block bool) error {
+	if message.Priority == worker.PriorityControl {
+		return m.pushControl(message, block)
+	}
+
+	var deadline <-chan time.Time
+
+	m.mu.Lock()
+	for {
+		if m.closed {
+			m.mu.Unlock()
+			return errMailboxClosed
+		}
+		if m.options.Overflow == worker.OverflowConflateByTag && m.replaceTagged(message) {
+			m.pushed++
+			m.dropped++
+			m.mu.Unlock()
+			return nil
+		}
+		if len(m.queue) < m.capacity {
+			m.pushed++
+			m.enqueue(message)
+			m.mu.Unlock()
+			return nil
+		}
+
+		switch m.options.Overflow {
+		case worker.OverflowDropNewest:
+			m.pushed++
+			m.dropped++
+			m.mu.Unlock()
+			return nil
+		case worker.OverflowDropOldest, worker.OverflowConflateByTag:
+			m.queue[0] = nil
+			m.queue = m.queue[1:]
+			m.pushed++
+			m.dropped++
+			m.enqueue(message)
+			m.mu.Unlock()
+			return nil
+		case worker.OverflowBlock:
+			if deadline == nil && m.options.BlockTimeout > 0 {
+				timer := time.NewTimer(m.options.BlockTimeout)
+				defer timer.Stop()
+				deadline = timer.C
+			}
+		default:
+			if !block {
+				m.rejected++
+				m.mu.Unlock()
+				return errMailboxFull
+			}
+		}
+
+		// Wait for the pump to take a message, then try again.
+		if !m.waitForSpace(deadline) {
+			m.rejected++
+			m.mu.Unlock()
+			return errMailboxTimeout
+		}
+	}
+}
+
+// pushControl queues a control message, which is not subject to the overflow policy. If the control lane is full,
+// block waits for space without a limit instead of failing.
+func (m *mailbox) pushControl(message worker.Message, block bool) error {
+	m.mu.Lock()
+	for {
+		if m.closed {
+			m.mu.Unlock()
+			return errMailboxClosed
+		}
+		if m.options.ControlCapacity == 0 {
+			m.rejected++
+			m.mu.Unlock()
+			return errNoControlLane
+		}
+		if len(m.control) < m.options.ControlCapacity {
+			m.pushed++
+			m.enqueue(message)
+			m.mu.Unlock()
+			return nil
+		}
+		if !block {
+			m.rejected++
+			m.mu.Unlock()
+			return errControlLaneFull
+		}
+
+		m.waitForSpace(nil)
+	}
+}
+
+// waitForSpace releases the mailbox lock until the pump takes a message, the mailbox is removed or the deadline
+// passes, reporting false on the deadline. The lock is held again on return. A wakeup may find the mailbox still
+// full, so the caller checks again.
+func (m *mailbox) waitForSpace(deadline <-chan time.Time) bool {
+	m.waiting++
+	m.mu.Unlock()
+
+	woken := true
+	select {
+	case <-m.space:
+	case <-m.closing:
+	case <-deadline:
+		woken = false
+	}
+
+	m.mu.Lock()
+	m.waiting--
+	return woken
+}
+
+// enqueue appends a message to its lane and wakes the pump. If the lane still has room, it passes the space signal
+// on to the next waiting producer, as the pump may have taken several messages for a single signal. The mailbox lock
+// must be held.
+func (m *mailbox) enqueue(message worker.Message) {
+	room := false
+	if message.Priority == worker.PriorityControl {
+		m.control = append(m.control, message)
+		room = len(m.control) < m.options.ControlCapacity
+	} else {
+		m.queue = append(m.queue, message)
+		room = len(m.queue) < m.capacity
+	}
+	signal(m.ready)
+	if room && m.waiting > 0 {
+		signal(m.space)
+	}
+}
+
+// replaceTagged replaces the queued message sharing the new message's tag, reporting whether there was one.
+// The mailbox lock must be held.
+func (m *mailbox) replaceTagged(message worker.Message) bool {
+	for i, queued := range m.queue {
+		if queuedMessage, ok := queued.(worker.Message); ok && queuedMessage.Tag == message.Tag {
+			m.queue[i] = message
+			return true
+		}
+	}
+	return false
+}
+
+// run delivers queued messages to the worker until the mailbox is removed, then closes the worker's channel.
+func (m *mailbox) run(done func()) {
+	defer done()
+	defer close(m.out)
+
+	for {
+		m.mu.Lock()
+		if m.closed {
+			m.mu.Unlock()
+			return
+		}
+		if len(m.control) == 0 && len(m.queue) == 0 {
+			m.mu.Unlock()
+			select {
+			case <-m.ready:
+			case <-m.closing:
+			}
+			continue
+		}
+
+		message := m.dequeue()
+		m.holding = true
+		if m.waiting > 0 {
+			signal(m.space)
+		}
+		m.mu.Unlock()
+
+		if m.received != nil {
+			message = m.received(message)
+		}
+
+		select {
+		case m.out <- message:
+		case <-m.closing:
+			return
+		}
+
+		m.mu.Lock()
+		m.holding = false
+		m.mu.Unlock()
+	}
+}
+
+// dequeue removes the next message, from the control lane if it holds any. The mailbox lock must be held and a lane
+// must hold a message.
+func (m *mailbox) dequeue() any {
+	lane := &m.queue
+	if len(m.control) > 0 {
+		lane = &m.control
+	}
+	message := (*lane)[0]
+	(*lane)[0] = nil
+	*lane = (*lane)[1:]
+	return message
+}
+
+// length returns the number of messages not yet taken by the worker.
+func (m *mailbox) length() int {
+	return m.stats().Depth
+}
+
+// stats returns the mailbox's counters and current depth.
+func (m *mailbox) stats() MailboxStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	depth := len(m.queue) + len(m.control)
+	if m.holding {
+		depth++
+	}
+	return MailboxStats{
+		Capacity:     m.capacity,
+		Depth:        depth,
+		ControlDepth: len(m.control),
+		Overflow:     m.options.Overflow,
+		Backend:      worker.BackendQueue,
+		Pushed:       m.pushed,
+		Dropped:      m.dropped,
+		Rejected:     m.rejected,
+	}
+}
+
+// close discards the queued messages and stops the pump. Pushes after close fail instead of panicking.
+func (m *mailbox) close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return
+	}
+	m.closed = true
+	m.queue = nil
+	m.control = nil
+	close(m.closing)
+}
+
+// channelInbox receives from the channel of a queue mailbox. It implements worker.Inbox.
+type channelInbox <-chan any
+
+// Receive waits for the next message on the channel.
+func (c channelInbox) Receive(ctx context.Context) (worker.Message, error) {
+	select {
+	case received, ok := <-c:
+		if !ok {
+			return worker.Message{}, worker.ErrInboxClosed
+		}
+		return asMessage(received)
+	case <-ctx.Done():
+		return worker.Message{}, ctx.Err()
+	}
+}
+
+// TryReceive returns the next message on the channel without waiting.
+func (c channelInbox) TryReceive() (worker.Message, bool) {
+	select {
+	case received, ok := <-c:
+		if !ok {
+			return worker.Message{}, false
+		}
+		message, err := asMessage(received)
+		return message, err == nil
+	default:
+		return worker.Message{}, false
+	}
+}
+
+// asMessage unboxes a message received from a mailbox channel.
+func asMessage(received any) (worker.Message, error) {
+	message, ok := received.(worker.Message)
+	if !ok {
+		return worker.Message{}, fmt.Errorf("received %T instead of worker.Message", received)
+	}
+	return message, nil
+}
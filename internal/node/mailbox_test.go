@@ -0,0 +1,226 @@
+package node
+
+import (
+	"errors"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/google/uuid"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestMailbox creates a mailbox through the dispatcher without starting its processing goroutine, so the test
+// drains it with drainMailbox.
+func newTestMailbox(t *testing.T, capacity int, opts ...worker.MailboxOption) mailboxQueue {
+	t.Helper()
+	mb, err := NewDispatcher().createMailbox(uuid.New(), capacity, false, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mb.close)
+	return mb
+}
+
+// drainMailbox removes every queued message in delivery order and returns their payloads.
+func drainMailbox(t *testing.T, mb mailboxQueue) []any {
+	t.Helper()
+	var payloads []any
+	switch mb := mb.(type) {
+	case *mailbox:
+		mb.mu.Lock()
+		defer mb.mu.Unlock()
+		for len(mb.control) > 0 || len(mb.queue) > 0 {
+			payloads = append(payloads, mb.dequeue().(worker.Message).Payload)
+		}
+	case *channelMailbox:
+		for len(mb.buffer) > 0 {
+			payloads = append(payloads, (<-mb.buffer).(worker.Message).Payload)
+		}
+	case *ringMailbox:
+		for {
+			message, ok := mb.TryReceive()
+			if !ok {
+				break
+			}
+			payloads = append(payloads, message.Payload)
+		}
+	default:
+		t.Fatalf("unknown mailbox backend %T", mb)
+	}
+	return payloads
+}
+
+func TestMailboxOverflowPolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		overflow worker.OverflowPolicy
+		backends []worker.MailboxBackend
+		wantErr  error
+		want     []any
+		dropped  uint64
+		rejected uint64
+	}{
+		{
+			name:     "reject fails the send",
+			overflow: worker.OverflowReject,
+			backends: []worker.MailboxBackend{worker.BackendQueue, worker.BackendRing},
+			wantErr:  errMailboxFull,
+			want:     []any{1, 2},
+			rejected: 1,
+		},
+		{
+			name:     "block fails the send after the timeout",
+			overflow: worker.OverflowBlock,
+			backends: []worker.MailboxBackend{worker.BackendQueue, worker.BackendRing},
+			wantErr:  errMailboxTimeout,
+			want:     []any{1, 2},
+			rejected: 1,
+		},
+		{
+			name:     "drop-newest discards the new message",
+			overflow: worker.OverflowDropNewest,
+			backends: []worker.MailboxBackend{worker.BackendQueue, worker.BackendRing},
+			want:     []any{1, 2},
+			dropped:  1,
+		},
+		{
+			name:     "drop-oldest discards the oldest message",
+			overflow: worker.OverflowDropOldest,
+			backends: []worker.MailboxBackend{worker.BackendQueue},
+			want:     []any{2, 3},
+			dropped:  1,
+		},
+		{
+			name:     "conflate-by-tag replaces the message with the same tag",
+			overflow: worker.OverflowConflateByTag,
+			backends: []worker.MailboxBackend{worker.BackendQueue},
+			want:     []any{3, 2},
+			dropped:  1,
+		},
+	}
+
+	// The first and last messages share a tag, for conflate-by-tag to replace.
+	messages := []worker.Message{
+		{Tag: "a", Payload: 1},
+		{Tag: "b", Payload: 2},
+		{Tag: "a", Payload: 3},
+	}
+
+	for _, tt := range tests {
+		for _, backend := range tt.backends {
+			t.Run(tt.name+"/"+string(backend), func(t *testing.T) {
+				mb := newTestMailbox(t, 2, worker.WithBackend(backend), worker.WithOverflow(tt.overflow),
+					worker.WithBlockTimeout(10*time.Millisecond))
+
+				var err error
+				for _, message := range messages {
+					if err = mb.push(message, false); err != nil {
+						break
+					}
+				}
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("last push returned %v, want %v", err, tt.wantErr)
+				}
+
+				stats := mb.stats()
+				if stats.Dropped != tt.dropped || stats.Rejected != tt.rejected {
+					t.Errorf("dropped %d and rejected %d, want %d and %d", stats.Dropped, stats.Rejected, tt.dropped, tt.rejected)
+				}
+				if got := drainMailbox(t, mb); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("received %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestMailboxBackendSelection(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []worker.MailboxOption
+		want mailboxQueue
+	}{
+		{"default", nil, &channelMailbox{}},
+		{"block", []worker.MailboxOption{worker.WithOverflow(worker.OverflowBlock)}, &channelMailbox{}},
+		{"drop-newest", []worker.MailboxOption{worker.WithOverflow(worker.OverflowDropNewest)}, &channelMailbox{}},
+		{"drop-oldest", []worker.MailboxOption{worker.WithOverflow(worker.OverflowDropOldest)}, &mailbox{}},
+		{"conflate-by-tag", []worker.MailboxOption{worker.WithOverflow(worker.OverflowConflateByTag)}, &mailbox{}},
+		{"control lane", []worker.MailboxOption{worker.WithControlCapacity(1)}, &mailbox{}},
+		{"ring", []worker.MailboxOption{worker.WithBackend(worker.BackendRing)}, &ringMailbox{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mb := newTestMailbox(t, 2, tt.opts...)
+			if reflect.TypeOf(mb) != reflect.TypeOf(tt.want) {
+				t.Errorf("created %T, want %T", mb, tt.want)
+			}
+		})
+	}
+}
+
+func TestMailboxControlLane(t *testing.T) {
+	for _, backend := range []worker.MailboxBackend{worker.BackendQueue, worker.BackendRing} {
+		t.Run(string(backend), func(t *testing.T) {
+			mb := newTestMailbox(t, 2, worker.WithBackend(backend), worker.WithControlCapacity(2))
+
+			pushes := []struct {
+				message worker.Message
+				wantErr error
+			}{
+				{worker.Message{Payload: "data 1"}, nil},
+				{worker.Message{Payload: "control 1", Priority: worker.PriorityControl}, nil},
+				{worker.Message{Payload: "data 2"}, nil},
+				{worker.Message{Payload: "control 2", Priority: worker.PriorityControl}, nil},
+				{worker.Message{Payload: "control 3", Priority: worker.PriorityControl}, errControlLaneFull},
+			}
+			for _, push := range pushes {
+				if err := mb.push(push.message, false); !errors.Is(err, push.wantErr) {
+					t.Fatalf("pushing %v returned %v, want %v", push.message.Payload, err, push.wantErr)
+				}
+			}
+
+			if stats := mb.stats(); stats.ControlDepth != 2 || stats.Depth != 4 {
+				t.Errorf("depth %d with control depth %d, want 4 with 2", stats.Depth, stats.ControlDepth)
+			}
+			want := []any{"control 1", "control 2", "data 1", "data 2"}
+			if got := drainMailbox(t, mb); !reflect.DeepEqual(got, want) {
+				t.Errorf("received %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMailboxWithoutControlLane(t *testing.T) {
+	for _, backend := range []worker.MailboxBackend{worker.BackendQueue, worker.BackendRing} {
+		t.Run(string(backend), func(t *testing.T) {
+			mb := newTestMailbox(t, 2, worker.WithBackend(backend))
+
+			err := mb.push(worker.Message{Priority: worker.PriorityControl}, true)
+			if !errors.Is(err, errNoControlLane) {
+				t.Fatalf("pushing a control message returned %v, want %v", err, errNoControlLane)
+			}
+		})
+	}
+}
+
+func TestMailboxPushAfterClose(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []worker.MailboxOption
+	}{
+		{"channel", nil},
+		{"queue", []worker.MailboxOption{worker.WithOverflow(worker.OverflowDropOldest)}},
+		{"ring", []worker.MailboxOption{worker.WithBackend(worker.BackendRing)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mb := newTestMailbox(t, 2, tt.opts...)
+			mb.close()
+			if err := mb.push(worker.Message{}, true); !errors.Is(err, errMailboxClosed) {
+				t.Fatalf("push after close returned %v, want %v", err, errMailboxClosed)
+			}
+		})
+	}
+}
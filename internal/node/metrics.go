@@ -0,0 +1,184 @@
+package node
+
+import (
+	"github.com/PhillipMichelsen/Tessera/internal/metrics"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+	"time"
+)
+
+// workerExit identifies a worker exit counter.
+type workerExit struct {
+	workerType string
+	exitCode   worker.ExitCode
+}
+
+// nodeStats accumulates the node's metrics that are not derived from its state at scrape time.
+type nodeStats struct {
+	mu          sync.Mutex
+	sendLatency map[string]*metrics.Histogram
+	sendErrors  map[string]uint64
+	exits       map[workerExit]uint64
+}
+
+func newNodeStats() *nodeStats {
+	return &nodeStats{
+		sendLatency: make(map[string]*metrics.Histogram),
+		sendErrors:  make(map[string]uint64),
+		exits:       make(map[workerExit]uint64),
+	}
+}
+
+// observeSend records the duration and outcome of a message sent by a worker of the given type.
+func (s *nodeStats) observeSend(workerType string, duration time.Duration, err error) {
+	s.mu.Lock()
+	histogram, exists := s.sendLatency[workerType]
+	if !exists {
+		histogram = metrics.NewHistogram(metrics.LatencyBuckets)
+		s.sendLatency[workerType] = histogram
+	}
+	if err != nil {
+		s.sendErrors[workerType]++
+	}
+	s.mu.Unlock()
+
+	histogram.ObserveDuration(duration)
+}
+
+// recordExit counts an exit of a worker of the given type.
+func (s *nodeStats) recordExit(workerType string, exitCode worker.ExitCode) {
+	s.mu.Lock()
+	s.exits[workerExit{workerType: workerType, exitCode: exitCode}]++
+	s.mu.Unlock()
+}
+
+// Collect writes the node's mailbox, send, worker, trace, topic and dead-letter metrics.
+func (n *Node) Collect(w *metrics.Writer) {
+	n.collectMailboxes(w)
+	n.stats.collect(w)
+	n.collectWorkers(w)
+	n.collectTraces(w)
+	n.collectTopics(w)
+	n.collectDeadLetters(w)
+}
+
+// collectMailboxes writes the depth and counters of every mailbox, labelled with the type of its owner.
+func (n *Node) collectMailboxes(w *metrics.Writer) {
+	owners := make(map[uuid.UUID]string)
+	n.mu.Lock()
+	for _, wc := range n.workers {
+		if wc.services == nil {
+			continue
+		}
+		for _, mailboxUUID := range wc.services.ownedMailboxes() {
+			owners[mailboxUUID] = wc.workerType
+		}
+	}
+	n.mu.Unlock()
+
+	stats := n.dispatcher.MailboxStats()
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].UUID.String() < stats[j].UUID.String()
+	})
+
+	families := []struct {
+		name       string
+		help       string
+		metricType metrics.MetricType
+		value      func(MailboxStats) float64
+	}{
+		{"tessera_mailbox_depth", "Messages queued in the mailbox and not yet taken by its worker.", metrics.GaugeType,
+			func(s MailboxStats) float64 { return float64(s.Depth) }},
+		{"tessera_mailbox_control_depth", "Control messages queued in the mailbox's control lane.", metrics.GaugeType,
+			func(s MailboxStats) float64 { return float64(s.ControlDepth) }},
+		{"tessera_mailbox_capacity", "Maximum number of messages queued in the mailbox.", metrics.GaugeType,
+			func(s MailboxStats) float64 { return float64(s.Capacity) }},
+		{"tessera_mailbox_pushed_total", "Messages accepted by the mailbox.", metrics.CounterType,
+			func(s MailboxStats) float64 { return float64(s.Pushed) }},
+		{"tessera_mailbox_dropped_total", "Messages discarded by the mailbox's overflow policy.", metrics.CounterType,
+			func(s MailboxStats) float64 { return float64(s.Dropped) }},
+		{"tessera_mailbox_rejected_total", "Pushes that failed because the mailbox was full.", metrics.CounterType,
+			func(s MailboxStats) float64 { return float64(s.Rejected) }},
+	}
+
+	for _, family := range families {
+		w.Header(family.name, family.help, family.metricType)
+		for _, mailboxStats := range stats {
+			w.Sample(family.name, family.value(mailboxStats),
+				"mailbox", mailboxStats.UUID.String(),
+				"worker_type", owners[mailboxStats.UUID],
+				"overflow", string(mailboxStats.Overflow),
+				"backend", string(mailboxStats.Backend),
+			)
+		}
+	}
+}
+
+// collect writes the send latencies and worker exits recorded so far.
+func (s *nodeStats) collect(w *metrics.Writer) {
+	s.mu.Lock()
+	workerTypes := make([]string, 0, len(s.sendLatency))
+	for workerType := range s.sendLatency {
+		workerTypes = append(workerTypes, workerType)
+	}
+	sort.Strings(workerTypes)
+
+	snapshots := make([]metrics.HistogramSnapshot, len(workerTypes))
+	sendErrors := make([]uint64, len(workerTypes))
+	for i, workerType := range workerTypes {
+		snapshots[i] = s.sendLatency[workerType].Snapshot()
+		sendErrors[i] = s.sendErrors[workerType]
+	}
+
+	exits := make([]workerExit, 0, len(s.exits))
+	for exit := range s.exits {
+		exits = append(exits, exit)
+	}
+	sort.Slice(exits, func(i, j int) bool {
+		if exits[i].workerType != exits[j].workerType {
+			return exits[i].workerType < exits[j].workerType
+		}
+		return exits[i].exitCode < exits[j].exitCode
+	})
+	exitCounts := make([]uint64, len(exits))
+	for i, exit := range exits {
+		exitCounts[i] = s.exits[exit]
+	}
+	s.mu.Unlock()
+
+	w.Header("tessera_worker_send_duration_seconds", "Time taken by workers to send a message, by worker type.", metrics.HistogramType)
+	for i, workerType := range workerTypes {
+		w.Histogram("tessera_worker_send_duration_seconds", snapshots[i], "worker_type", workerType)
+	}
+
+	w.Header("tessera_worker_send_errors_total", "Messages that workers failed to send, by worker type.", metrics.CounterType)
+	for i, workerType := range workerTypes {
+		w.Sample("tessera_worker_send_errors_total", float64(sendErrors[i]), "worker_type", workerType)
+	}
+
+	w.Header("tessera_worker_exits_total", "Worker exits, by worker type and exit code.", metrics.CounterType)
+	for i, exit := range exits {
+		w.Sample("tessera_worker_exits_total", float64(exitCounts[i]), "worker_type", exit.workerType, "exit_code", exit.exitCode.String())
+	}
+}
+
+// collectWorkers writes whether each worker is active and how many times it has been restarted.
+func (n *Node) collectWorkers(w *metrics.Writer) {
+	workers := n.ListWorkers()
+
+	w.Header("tessera_worker_up", "Whether the worker is active.", metrics.GaugeType)
+	for _, info := range workers {
+		up := 0.0
+		if info.Active {
+			up = 1
+		}
+		w.Sample("tessera_worker_up", up, "worker", info.UUID.String(), "worker_type", info.Type)
+	}
+
+	w.Header("tessera_worker_restarts_total", "Restarts of the worker under its restart policy since it was started.", metrics.CounterType)
+	for _, info := range workers {
+		w.Sample("tessera_worker_restarts_total", float64(info.RestartCount), "worker", info.UUID.String(), "worker_type", info.Type)
+	}
+}
@@ -2,20 +2,49 @@ package node
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/PhillipMichelsen/Tessera/internal/worker/config"
 	"github.com/google/uuid"
+	"sort"
 	"sync"
 	"time"
 )
 
+// DefaultReadyTimeout bounds how long a start_worker instruction waits for the worker to be ready unless the
+// instruction sets its own timeout.
+const DefaultReadyTimeout = 30 * time.Second
+
+// WorkerState is the stage of its lifecycle a worker is in.
+type WorkerState string
+
+const (
+	// WorkerStopped is a worker that is not active.
+	WorkerStopped WorkerState = "stopped"
+	// WorkerStarting is an active worker that has not yet reported it is ready since it was last launched.
+	WorkerStarting WorkerState = "starting"
+	// WorkerRunning is an active worker that has reported it is ready.
+	WorkerRunning WorkerState = "running"
+	// WorkerRestarting is an active worker waiting out its backoff before being restarted.
+	WorkerRestarting WorkerState = "restarting"
+)
+
 // WorkerStatus tracks the state of a worker.
 type WorkerStatus struct {
 	isActive  bool
+	ready     bool
 	exitCode  worker.ExitCode
 	error     error
 	lastStart time.Time
 	lastExit  time.Time
+
+	// Supervision state, maintained according to the worker's restart policy.
+	restarting          bool
+	restartCount        int
+	consecutiveRestarts int
+	lastFailure         error
+	lastFailureTime     time.Time
 }
 
 // WorkerContainer wraps a worker along with its status and control channels.
@@ -24,78 +53,251 @@ type WorkerContainer struct {
 	worker     worker.Worker
 	workerType string
 	status     WorkerStatus
-	services   WorkerServices
+	services   *WorkerServices
+	sequences  *sequencer
 	cancelFunc context.CancelFunc
 	done       chan struct{}
+
+	// Retained from start_worker so the worker can be restarted under its restart policy.
+	ctx           context.Context
+	rawConfig     any
+	restartPolicy RestartPolicy
+}
+
+// WorkerInfo is a point-in-time snapshot of a worker's identity and status.
+type WorkerInfo struct {
+	UUID           uuid.UUID   `json:"uuid"`
+	Type           string      `json:"type"`
+	Active         bool        `json:"active"`
+	State          WorkerState `json:"state"`
+	UptimeSeconds  float64     `json:"uptime_seconds"`
+	LastStart      time.Time   `json:"last_start"`
+	LastExit       time.Time   `json:"last_exit"`
+	LastExitCode   string      `json:"last_exit_code"`
+	LastError      string      `json:"last_error,omitempty"`
+	Restarting     bool        `json:"restarting"`
+	RestartCount   int         `json:"restart_count"`
+	LastFailure    string      `json:"last_failure,omitempty"`
+	LastFailureAt  time.Time   `json:"last_failure_at"`
+	OwnedMailboxes []uuid.UUID `json:"owned_mailboxes"`
 }
 
 type WorkerFactory interface {
 	InstantiateWorker(workerType string) (worker.Worker, error)
 }
 
+// ConfigRegistry is implemented by worker factories that know the config types of their workers, so that configs
+// can be checked and described without instantiating workers.
+type ConfigRegistry interface {
+	CheckConfig(workerType string, rawConfig any) error
+	ConfigSchemas() map[string]config.Schema
+}
+
+// MailboxAnnouncer publishes the ownership of this node's mailboxes to the rest of the cluster.
+// Implemented by the discovery service; both methods must not block.
+type MailboxAnnouncer interface {
+	Announce(mailboxUUID uuid.UUID)
+	Withdraw(mailboxUUID uuid.UUID)
+}
+
+// MessageBridge forwards messages to mailboxes owned by other nodes.
+type MessageBridge interface {
+	Send(destinationMailboxUUID uuid.UUID, message worker.Message, block bool) error
+}
+
+// MailboxResolver resolves the address of the node owning a mailbox. Implemented by bridges routing through static
+// routes or discovery.
+type MailboxResolver interface {
+	ResolveMailbox(mailboxUUID uuid.UUID) (address string, ok bool)
+}
+
 // Node represents the node which holds and manages workers.
 type Node struct {
 	dispatcher    *Dispatcher
+	events        *EventBus
+	stats         *nodeStats
+	traces        *traceCollector
+	topics        *topicRegistry
+	deadLetters   *deadLetterQueue // Guarded by its own lock, so failed sends never take the node lock.
 	workerFactory WorkerFactory
 	workers       map[uuid.UUID]*WorkerContainer
+	bridge        MessageBridge
+	shuttingDown  bool
 	mu            sync.Mutex
 }
 
 // NewNode initializes a new Node instance.
 func NewNode(workerFactory WorkerFactory) *Node {
+	traces := newTraceCollector()
+	dispatcher := NewDispatcher()
+	dispatcher.traces = traces
+
 	return &Node{
-		dispatcher:    NewDispatcher(),
+		dispatcher:    dispatcher,
+		events:        NewEventBus(),
+		stats:         newNodeStats(),
+		traces:        traces,
+		topics:        newTopicRegistry(),
+		deadLetters:   newDeadLetterQueue(DefaultDeadLetterCapacity),
 		workerFactory: workerFactory,
 		workers:       make(map[uuid.UUID]*WorkerContainer),
 	}
 }
 
-func (n *Node) ProcessTask(task Task) error {
-	for _, instruction := range task.Instructions {
-		switch instruction.Type {
-		case "create_worker":
-			args, ok := instruction.Args.(CreateWorkerInstructionArgs)
-			if !ok {
-				return fmt.Errorf("failed to decode create_worker args")
-			}
+// ProcessTask validates the task and then executes its instructions in order, stopping at the first failure.
+// It returns a result for every instruction, marking those after a failure as skipped. If the task is atomic,
+// the instructions applied before the failure are rolled back. If validation fails,
+// nothing is applied, the instructions with issues are marked as rejected and a *ValidationError is returned.
+// Validation warnings are logged and do not stop the task.
+func (n *Node) ProcessTask(task Task) ([]InstructionResult, error) {
+	results := make([]InstructionResult, len(task.Instructions))
+	for i, instruction := range task.Instructions {
+		results[i] = InstructionResult{
+			Index:  i,
+			Type:   instruction.Type,
+			Status: InstructionSkipped,
+		}
+	}
+
+	n.mu.Lock()
+	shuttingDown := n.shuttingDown
+	n.mu.Unlock()
+	if shuttingDown {
+		return results, fmt.Errorf("node is shutting down")
+	}
 
-			if err := n.createWorker(args.WorkerType, args.WorkerUUID); err != nil {
-				return fmt.Errorf("error creating worker: %v", err)
+	validationErr := n.ValidateTasks(task)
+	if validationErr != nil {
+		for _, warning := range validationErr.Warnings {
+			fmt.Printf("Warning for instruction %d (%s): %s\n", warning.Instruction, warning.Type, warning.Message)
+		}
+	}
+	if validationErr.HasIssues() {
+		for _, issue := range validationErr.Issues {
+			result := &results[issue.Instruction]
+			result.Status = InstructionRejected
+			if result.Error != "" {
+				result.Error += "; "
 			}
+			result.Error += issue.Message
+		}
+		return results, validationErr
+	}
 
-		case "start_worker":
-			args, ok := instruction.Args.(StartWorkerInstructionArgs)
-			if !ok {
-				return fmt.Errorf("failed to decode start_worker args")
+	for i, instruction := range task.Instructions {
+		if err := n.processInstruction(instruction); err != nil {
+			results[i].Status = InstructionFailed
+			results[i].Error = err.Error()
+			if task.Atomic {
+				n.rollbackTask(task, results[:i])
 			}
+			return results, fmt.Errorf("instruction %d (%s) failed: %w", i, instruction.Type, err)
+		}
+		results[i].Status = InstructionApplied
+	}
+
+	return results, nil
+}
+
+// rollbackTask undoes the applied instructions of a failed task in reverse order, stopping the workers it
+// started and removing the workers it created. Stop, remove and reconfigure instructions cannot be undone and stay
+// applied.
+func (n *Node) rollbackTask(task Task, applied []InstructionResult) {
+	for i := len(applied) - 1; i >= 0; i-- {
+		var err error
+		switch args := task.Instructions[i].Args.(type) {
+		case StartWorkerInstructionArgs:
+			err = n.rollbackStart(args.WorkerUUID)
+		case CreateWorkerInstructionArgs:
+			err = n.removeWorker(args.WorkerUUID)
+		default:
+			continue
+		}
+
+		if err != nil {
+			applied[i].Status = InstructionRollbackFailed
+			applied[i].Error = err.Error()
+			fmt.Printf("Failed to roll back instruction %d (%s): %v\n", i, task.Instructions[i].Type, err)
+			continue
+		}
+		applied[i].Status = InstructionRolledBack
+	}
+}
+
+// rollbackStart stops a worker started by a failed task. A worker that has already exited is left as is.
+func (n *Node) rollbackStart(workerUUID uuid.UUID) error {
+	n.mu.Lock()
+	wc, exists := n.workers[workerUUID]
+	active := exists && wc.status.isActive
+	n.mu.Unlock()
+
+	if !active {
+		return nil
+	}
+	return n.stopWorker(workerUUID)
+}
+
+// processInstruction applies a single decoded instruction to the node.
+func (n *Node) processInstruction(instruction Instruction) error {
+	switch instruction.Type {
+	case "create_worker":
+		args, ok := instruction.Args.(CreateWorkerInstructionArgs)
+		if !ok {
+			return fmt.Errorf("failed to decode create_worker args")
+		}
+
+		if err := n.createWorker(args.WorkerType, args.WorkerUUID); err != nil {
+			return fmt.Errorf("error creating worker: %v", err)
+		}
+
+	case "start_worker":
+		args, ok := instruction.Args.(StartWorkerInstructionArgs)
+		if !ok {
+			return fmt.Errorf("failed to decode start_worker args")
+		}
 
-			if err := n.startWorker(args.WorkerUUID, args.WorkerRawConfig); err != nil {
+		services, err := n.startWorker(args.WorkerUUID, args.WorkerRawConfig, args.RestartPolicy)
+		if err != nil {
+			return fmt.Errorf("error starting worker: %v", err)
+		}
+		if args.WaitReady {
+			if err := n.waitWorkerReady(args.WorkerUUID, services, args.ReadyTimeout); err != nil {
 				return fmt.Errorf("error starting worker: %v", err)
 			}
+		}
 
-		case "remove_worker":
-			args, ok := instruction.Args.(RemoveWorkerInstructionArgs)
-			if !ok {
-				return fmt.Errorf("failed to decode remove_worker args")
-			}
+	case "remove_worker":
+		args, ok := instruction.Args.(RemoveWorkerInstructionArgs)
+		if !ok {
+			return fmt.Errorf("failed to decode remove_worker args")
+		}
 
-			if err := n.removeWorker(args.WorkerUUID); err != nil {
-				return fmt.Errorf("error removing worker: %v", err)
-			}
+		if err := n.removeWorker(args.WorkerUUID); err != nil {
+			return fmt.Errorf("error removing worker: %v", err)
+		}
 
-		case "stop_worker":
-			args, ok := instruction.Args.(StopWorkerInstructionArgs)
-			if !ok {
-				return fmt.Errorf("failed to decode stop_worker args")
-			}
+	case "stop_worker":
+		args, ok := instruction.Args.(StopWorkerInstructionArgs)
+		if !ok {
+			return fmt.Errorf("failed to decode stop_worker args")
+		}
 
-			if err := n.stopWorker(args.WorkerUUID); err != nil {
-				return fmt.Errorf("error stopping worker: %v", err)
-			}
+		if err := n.stopWorker(args.WorkerUUID); err != nil {
+			return fmt.Errorf("error stopping worker: %v", err)
+		}
 
-		default:
-			return fmt.Errorf("unknown instruction: %s", instruction.Type)
+	case "reconfigure_worker":
+		args, ok := instruction.Args.(ReconfigureWorkerInstructionArgs)
+		if !ok {
+			return fmt.Errorf("failed to decode reconfigure_worker args")
+		}
+
+		if err := n.reconfigureWorker(args.WorkerUUID, args.WorkerRawConfig); err != nil {
+			return fmt.Errorf("error reconfiguring worker: %v", err)
 		}
+
+	default:
+		return fmt.Errorf("unknown instruction: %s", instruction.Type)
 	}
 
 	return nil
@@ -105,6 +307,185 @@ func (n *Node) ParseTask(yamlBytes []byte) (Task, error) {
 	return parseTaskFromYaml(yamlBytes)
 }
 
+// ConfigSchemas returns the JSON Schema of the config of every worker type whose config type is registered with the
+// node's worker factory.
+func (n *Node) ConfigSchemas() map[string]config.Schema {
+	registry, ok := n.workerFactory.(ConfigRegistry)
+	if !ok {
+		return map[string]config.Schema{}
+	}
+	return registry.ConfigSchemas()
+}
+
+// AttachBridge routes messages for mailboxes that are not local to this node through the bridge.
+func (n *Node) AttachBridge(bridge MessageBridge) {
+	n.mu.Lock()
+	n.bridge = bridge
+	n.mu.Unlock()
+}
+
+// AttachDiscovery announces every mailbox on the node, and every mailbox created or removed from now on.
+func (n *Node) AttachDiscovery(announcer MailboxAnnouncer) {
+	n.dispatcher.AddMailboxObserver(func(created bool, mailboxUUID uuid.UUID) {
+		if created {
+			announcer.Announce(mailboxUUID)
+		} else {
+			announcer.Withdraw(mailboxUUID)
+		}
+	})
+
+	for _, mailboxUUID := range n.dispatcher.ListMailboxes() {
+		announcer.Announce(mailboxUUID)
+	}
+}
+
+// messageBridge returns the attached bridge, or nil if the node is not bridged.
+func (n *Node) messageBridge() MessageBridge {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.bridge
+}
+
+// sendMessage routes a message to a local mailbox, or through the bridge if the mailbox is not on this node.
+func (n *Node) sendMessage(destinationMailboxUUID uuid.UUID, message worker.Message, block bool) error {
+	// Intra-node message case, can be directly pushed to mailbox.
+	err := n.dispatcher.push(destinationMailboxUUID, message, block)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, errMailboxNotFound) {
+		return fmt.Errorf("failed to send message to destination mailbox %s: %w", destinationMailboxUUID, err)
+	}
+
+	// Inter-node message case, needs to be routed through the bridge, which resolves the destination node.
+	bridge := n.messageBridge()
+	if bridge == nil {
+		return fmt.Errorf("destination mailbox %s is not local and the node has no bridge", destinationMailboxUUID)
+	}
+
+	if err := bridge.Send(destinationMailboxUUID, message, block); err != nil {
+		return fmt.Errorf("failed to bridge message to destination mailbox %s: %w", destinationMailboxUUID, err)
+	}
+	return nil
+}
+
+// DeliverMessage pushes a message received from another node into a local mailbox.
+func (n *Node) DeliverMessage(mailboxUUID uuid.UUID, message worker.Message, block bool) error {
+	if block {
+		return n.dispatcher.PushMessageBlocking(mailboxUUID, message)
+	}
+	return n.dispatcher.PushMessage(mailboxUUID, message)
+}
+
+// SubscribeEvents returns a channel receiving the node's lifecycle events and a function that cancels the
+// subscription. Events are dropped while the channel's buffer is full.
+func (n *Node) SubscribeEvents(bufferSize int) (<-chan worker.Event, func()) {
+	events := make(chan worker.Event, bufferSize)
+
+	var once sync.Once
+	var mu sync.Mutex
+	closed := false
+	subscriptionID := n.events.Subscribe(func(event worker.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case events <- event:
+		default:
+		}
+	})
+
+	cancel := func() {
+		once.Do(func() {
+			n.events.Unsubscribe(subscriptionID)
+			mu.Lock()
+			closed = true
+			close(events)
+			mu.Unlock()
+		})
+	}
+
+	return events, cancel
+}
+
+// ListWorkers returns a snapshot of every registered worker, ordered by UUID.
+func (n *Node) ListWorkers() []WorkerInfo {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	infos := make([]WorkerInfo, 0, len(n.workers))
+	for _, wc := range n.workers {
+		infos = append(infos, wc.info())
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].UUID.String() < infos[j].UUID.String()
+	})
+
+	return infos
+}
+
+// GetWorker returns a snapshot of a single worker.
+func (n *Node) GetWorker(workerUUID uuid.UUID) (WorkerInfo, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	wc, exists := n.workers[workerUUID]
+	if !exists {
+		return WorkerInfo{}, fmt.Errorf("worker %s not registered", workerUUID)
+	}
+
+	return wc.info(), nil
+}
+
+// info builds a WorkerInfo snapshot of the container. The node lock must be held.
+func (wc *WorkerContainer) info() WorkerInfo {
+	info := WorkerInfo{
+		UUID:           wc.uuid,
+		Type:           wc.workerType,
+		Active:         wc.status.isActive,
+		State:          wc.status.state(),
+		LastStart:      wc.status.lastStart,
+		LastExit:       wc.status.lastExit,
+		LastExitCode:   wc.status.exitCode.String(),
+		Restarting:     wc.status.restarting,
+		RestartCount:   wc.status.restartCount,
+		LastFailureAt:  wc.status.lastFailureTime,
+		OwnedMailboxes: []uuid.UUID{},
+	}
+
+	if wc.status.error != nil {
+		info.LastError = wc.status.error.Error()
+	}
+	if wc.status.lastFailure != nil {
+		info.LastFailure = wc.status.lastFailure.Error()
+	}
+	if wc.status.isActive {
+		info.UptimeSeconds = time.Since(wc.status.lastStart).Seconds()
+	}
+	if wc.services != nil {
+		info.OwnedMailboxes = wc.services.ownedMailboxes()
+	}
+
+	return info
+}
+
+// state derives the lifecycle stage of the worker from its status.
+func (s WorkerStatus) state() WorkerState {
+	switch {
+	case !s.isActive:
+		return WorkerStopped
+	case s.restarting:
+		return WorkerRestarting
+	case !s.ready:
+		return WorkerStarting
+	default:
+		return WorkerRunning
+	}
+}
+
 // createWorker instantiates and registers a new worker.
 func (n *Node) createWorker(workerType string, workerUUID uuid.UUID) error {
 	instantiatedWorker, err := n.workerFactory.InstantiateWorker(workerType)
@@ -121,6 +502,7 @@ func (n *Node) createWorker(workerType string, workerUUID uuid.UUID) error {
 		worker:     instantiatedWorker,
 		workerType: workerType,
 		status:     WorkerStatus{isActive: false},
+		sequences:  newSequencer(),
 	}
 	n.workers[workerUUID] = wc
 
@@ -144,37 +526,111 @@ func (n *Node) removeWorker(workerUUID uuid.UUID) error {
 	return nil
 }
 
-// startWorker starts a worker using its configuration and node-provided services.
-func (n *Node) startWorker(workerUUID uuid.UUID, rawConfig any) error {
+// startWorker starts a worker using its configuration and node-provided services, returning the services of the run
+// it launched. The worker is supervised according to the restart policy until it is stopped.
+func (n *Node) startWorker(workerUUID uuid.UUID, rawConfig any, restartPolicy RestartPolicy) (*WorkerServices, error) {
 	n.mu.Lock()
 	wc, exists := n.workers[workerUUID]
 	if !exists || wc.status.isActive {
 		n.mu.Unlock()
-		return fmt.Errorf("worker %s not registered or already active", workerUUID)
+		return nil, fmt.Errorf("worker %s not registered or already active", workerUUID)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
+	wc.ctx = ctx
 	wc.cancelFunc = cancel
 	wc.done = make(chan struct{})
+	wc.rawConfig = rawConfig
+	wc.restartPolicy = restartPolicy
 	wc.status.isActive = true
+	wc.status.restartCount = 0
+	wc.status.consecutiveRestarts = 0
+	n.launchWorker(wc)
+	services := wc.services
+	n.mu.Unlock()
+
+	return services, nil
+}
+
+// launchWorker runs the worker in a new goroutine with fresh services. The node lock must be held.
+func (n *Node) launchWorker(wc *WorkerContainer) {
 	wc.status.lastStart = time.Now()
+	wc.status.ready = false
 	wc.status.error = nil
 	wc.status.exitCode = worker.NormalExit
-	wc.services = NewWorkerServices(n)
-	n.mu.Unlock()
+	wc.services = NewWorkerServices(n, wc.uuid, wc.workerType)
+	wc.services.sequences = wc.sequences
 
+	n.events.Publish(worker.Event{
+		Type:       worker.WorkerStartedEvent,
+		Time:       wc.status.lastStart,
+		WorkerUUID: wc.uuid,
+		WorkerType: wc.workerType,
+	})
+
+	ctx, rawConfig, services := wc.ctx, wc.rawConfig, wc.services
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				n.handleWorkerExit(workerUUID, worker.PanicExit, fmt.Errorf("%v", r))
+				n.handleWorkerExit(wc.uuid, worker.PanicExit, fmt.Errorf("%v", r))
 			}
 		}()
 
-		exitCode, err := wc.worker.Run(ctx, rawConfig, wc.services)
-		n.handleWorkerExit(workerUUID, exitCode, err)
+		exitCode, err := wc.worker.Run(ctx, rawConfig, services)
+		n.handleWorkerExit(wc.uuid, exitCode, err)
 	}()
+}
 
-	return nil
+// waitWorkerReady waits for the run of a started worker using the services to report it is ready. Runs launched
+// by later restarts are not waited for. A worker whose run exits first, or is not ready within the timeout, is
+// stopped.
+func (n *Node) waitWorkerReady(workerUUID uuid.UUID, services *WorkerServices, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultReadyTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-services.ready:
+		return nil
+	case <-services.exited:
+		// A worker that was ready before exiting has started as asked.
+		select {
+		case <-services.ready:
+			return nil
+		default:
+		}
+		err = fmt.Errorf("worker %s exited before it was ready", workerUUID)
+	case <-timer.C:
+		err = fmt.Errorf("worker %s was not ready within %s", workerUUID, timeout)
+	}
+
+	if stopErr := n.rollbackStart(workerUUID); stopErr != nil {
+		return errors.Join(err, stopErr)
+	}
+	return err
+}
+
+// markReady records that the run of the worker using the services is ready. Reports from a run that has since
+// exited are ignored.
+func (n *Node) markReady(services *WorkerServices) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	wc, exists := n.workers[services.workerUUID]
+	if !exists || wc.services != services {
+		return
+	}
+
+	wc.status.ready = true
+	n.events.Publish(worker.Event{
+		Type:       worker.WorkerReadyEvent,
+		Time:       time.Now(),
+		WorkerUUID: wc.uuid,
+		WorkerType: wc.workerType,
+	})
 }
 
 // stopWorker stops a running worker. Blocks until the worker exits.
@@ -200,7 +656,56 @@ func (n *Node) stopWorker(workerUUID uuid.UUID) error {
 	return nil
 }
 
-// handleWorkerExit updates the status of a worker once it exits.
+// reconfigureWorker gives an active worker a new config. A running worker implementing worker.Reconfigurable takes
+// it in place; other workers, and changes the worker can only apply on a restart, are stopped and started again
+// with it under the same restart policy.
+func (n *Node) reconfigureWorker(workerUUID uuid.UUID, rawConfig any) error {
+	n.mu.Lock()
+	wc, exists := n.workers[workerUUID]
+	if !exists || !wc.status.isActive {
+		n.mu.Unlock()
+		return fmt.Errorf("worker %s not registered or not active", workerUUID)
+	}
+
+	// A worker waiting to be restarted has no running instance to reconfigure.
+	reconfigurable, ok := wc.worker.(worker.Reconfigurable)
+	running := wc.services != nil
+	workerType := wc.workerType
+	restartPolicy := wc.restartPolicy
+	n.mu.Unlock()
+
+	// The worker is reconfigured without the node lock, which its sends and exit take.
+	if ok && running {
+		err := reconfigurable.Reconfigure(rawConfig)
+		if err == nil {
+			n.mu.Lock()
+			if n.workers[workerUUID] == wc {
+				wc.rawConfig = rawConfig
+			}
+			n.mu.Unlock()
+
+			n.events.Publish(worker.Event{
+				Type:       worker.WorkerReconfiguredEvent,
+				Time:       time.Now(),
+				WorkerUUID: workerUUID,
+				WorkerType: workerType,
+			})
+			return nil
+		}
+		if !errors.Is(err, worker.ErrRestartRequired) {
+			return fmt.Errorf("failed to reconfigure worker %s: %w", workerUUID, err)
+		}
+		fmt.Printf("Restarting worker %s to reconfigure it: %v\n", workerUUID, err)
+	}
+
+	if err := n.stopWorker(workerUUID); err != nil {
+		return err
+	}
+	_, err := n.startWorker(workerUUID, rawConfig, restartPolicy)
+	return err
+}
+
+// handleWorkerExit updates the status of a worker once it exits, scheduling a restart if its policy requires one.
 func (n *Node) handleWorkerExit(workerUUID uuid.UUID, exitCode worker.ExitCode, err error) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
@@ -210,19 +715,81 @@ func (n *Node) handleWorkerExit(workerUUID uuid.UUID, exitCode worker.ExitCode,
 		return
 	}
 
-	wc.services.cleanupMailboxes()
+	wc.services.cleanup()
 
-	wc.status.isActive = false
+	now := time.Now()
 	wc.status.exitCode = exitCode
 	wc.status.error = err
-	wc.status.lastExit = time.Now()
-	wc.cancelFunc = nil
-	wc.services = WorkerServices{}
-	close(wc.done)
+	wc.status.lastExit = now
+	wc.services = nil
+	n.stats.recordExit(wc.workerType, exitCode)
+	if exitCode != worker.NormalExit {
+		wc.status.lastFailure = err
+		wc.status.lastFailureTime = now
+	}
+	if now.Sub(wc.status.lastStart) >= wc.restartPolicy.resetAfter() {
+		wc.status.consecutiveRestarts = 0
+	}
 
 	fmt.Printf("Worker %s exited with code %d and error: %v\n", workerUUID, exitCode, err)
 
-	// TODO: Implement propagation of worker exit codes to orchestrator.
-	// If worker exit code is not NormalExit, propagate to orchestrator.
-	// If worker exit code is NormalExit, do not propagate to orchestrator.
+	// A cancelled context means the node stopped the worker, which is never restarted.
+	willRestart := wc.ctx.Err() == nil && wc.restartPolicy.shouldRestart(exitCode, wc.status.consecutiveRestarts)
+
+	exitEvent := worker.Event{
+		Type:        worker.WorkerExitedEvent,
+		Time:        now,
+		WorkerUUID:  workerUUID,
+		WorkerType:  wc.workerType,
+		ExitCode:    exitCode,
+		WillRestart: willRestart,
+	}
+	if err != nil {
+		exitEvent.Error = err.Error()
+	}
+	n.events.Publish(exitEvent)
+
+	if willRestart {
+		delay := wc.restartPolicy.backoff(wc.status.consecutiveRestarts)
+		wc.status.restarting = true
+		go n.restartWorkerAfter(wc, delay)
+		return
+	}
+
+	n.finishWorker(wc)
+}
+
+// restartWorkerAfter relaunches the worker after the backoff delay, unless the worker is stopped in the meantime.
+func (n *Node) restartWorkerAfter(wc *WorkerContainer, delay time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-wc.ctx.Done():
+	case <-timer.C:
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	wc.status.restarting = false
+	if wc.ctx.Err() != nil {
+		n.finishWorker(wc)
+		return
+	}
+
+	wc.status.restartCount++
+	wc.status.consecutiveRestarts++
+	fmt.Printf("Restarting worker %s (restart %d)\n", wc.uuid, wc.status.restartCount)
+	n.launchWorker(wc)
+}
+
+// finishWorker marks a worker as inactive and releases anything waiting on it. The node lock must be held.
+func (n *Node) finishWorker(wc *WorkerContainer) {
+	wc.status.isActive = false
+	if wc.cancelFunc != nil {
+		wc.cancelFunc()
+	}
+	wc.cancelFunc = nil
+	close(wc.done)
 }
@@ -0,0 +1,54 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/google/uuid"
+)
+
+// Request sends the message to the destination mailbox and waits for its reply. The reply is delivered to an
+// ephemeral mailbox that exists only for the duration of the request, so late replies fail instead of reaching
+// a later request. Reply mailboxes are not announced to discovery, so only workers on the same node can reply.
+func (ws *WorkerServices) Request(ctx context.Context, destinationMailboxUUID uuid.UUID, message worker.Message) (worker.Message, error) {
+	replyMailboxUUID := uuid.New()
+	mb, err := ws.node.dispatcher.createMailbox(replyMailboxUUID, 1, true, worker.WithBackend(worker.BackendRing))
+	if err != nil {
+		return worker.Message{}, fmt.Errorf("failed to create reply mailbox: %w", err)
+	}
+	defer ws.node.dispatcher.removeMailbox(replyMailboxUUID, true)
+	replies := ws.node.dispatcher.inbox(mb)
+
+	message.ReplyTo = replyMailboxUUID
+	message.CorrelationID = uuid.New()
+	if err := ws.SendMessage(destinationMailboxUUID, message, false); err != nil {
+		return worker.Message{}, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	for {
+		reply, err := replies.Receive(ctx)
+		if err != nil {
+			return worker.Message{}, fmt.Errorf("request to mailbox %s got no reply: %w", destinationMailboxUUID, err)
+		}
+		if reply.CorrelationID == message.CorrelationID {
+			return reply, nil
+		}
+	}
+}
+
+// Reply sends the reply to the mailbox awaiting it, without blocking: a requester that has stopped waiting has
+// removed its reply mailbox, and a requester that is waiting always has room for the reply. Replies are not
+// sequenced, as each reply mailbox receives a single message, and a failed reply is not dead-lettered, as it only
+// means the requester gave up.
+func (ws *WorkerServices) Reply(request worker.Message, reply worker.Message) error {
+	if !request.IsRequest() {
+		return fmt.Errorf("message with tag %q is not a request", request.Tag)
+	}
+
+	reply.ReplyTo = uuid.Nil
+	reply.CorrelationID = request.CorrelationID
+	if err := ws.send(request.ReplyTo, reply, false, false); err != nil {
+		return fmt.Errorf("failed to reply to request %s: %w", request.CorrelationID, err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package node
+
+import (
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"math/rand/v2"
+	"time"
+)
+
+// RestartPolicyKind selects when the node restarts a worker after its Run returns.
+type RestartPolicyKind string
+
+const (
+	// RestartNever leaves the worker stopped after it exits. This is the default.
+	RestartNever RestartPolicyKind = "never"
+	// RestartOnFailure restarts the worker only if it exits with a non-normal exit code.
+	RestartOnFailure RestartPolicyKind = "on-failure"
+	// RestartAlways restarts the worker whenever it exits without being stopped by the node.
+	RestartAlways RestartPolicyKind = "always"
+)
+
+const (
+	defaultInitialBackoff = 1 * time.Second
+	defaultMaxBackoff     = 30 * time.Second
+	defaultResetAfter     = 1 * time.Minute
+)
+
+// RestartPolicy configures how the node supervises a worker started with start_worker.
+// Backoff doubles from InitialBackoff after each consecutive restart, capped at MaxBackoff, and is spread by
+// ±Jitter (a fraction between 0 and 1). A run lasting at least ResetAfter resets the consecutive restart count.
+// MaxRetries bounds the number of consecutive restarts, zero meaning unlimited.
+type RestartPolicy struct {
+	Policy         RestartPolicyKind `yaml:"policy,omitempty"`
+	MaxRetries     int               `yaml:"max_retries,omitempty"`
+	InitialBackoff time.Duration     `yaml:"initial_backoff,omitempty"`
+	MaxBackoff     time.Duration     `yaml:"max_backoff,omitempty"`
+	Jitter         float64           `yaml:"jitter,omitempty"`
+	ResetAfter     time.Duration     `yaml:"reset_after,omitempty"`
+}
+
+// validate checks the policy for invalid values.
+func (p RestartPolicy) validate() error {
+	switch p.Policy {
+	case "", RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("unknown restart policy %q", p.Policy)
+	}
+
+	if p.MaxRetries < 0 {
+		return fmt.Errorf("max_retries must not be negative")
+	}
+	if p.InitialBackoff < 0 || p.MaxBackoff < 0 || p.ResetAfter < 0 {
+		return fmt.Errorf("backoff durations must not be negative")
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("jitter must be between 0 and 1")
+	}
+
+	return nil
+}
+
+// shouldRestart reports whether a worker exiting with the given code should be restarted,
+// given the number of consecutive restarts already performed.
+func (p RestartPolicy) shouldRestart(exitCode worker.ExitCode, consecutiveRestarts int) bool {
+	if p.MaxRetries > 0 && consecutiveRestarts >= p.MaxRetries {
+		return false
+	}
+
+	switch p.Policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return exitCode != worker.NormalExit
+	default:
+		return false
+	}
+}
+
+// backoff returns the delay before the next restart, given the number of consecutive restarts already performed.
+func (p RestartPolicy) backoff(consecutiveRestarts int) time.Duration {
+	initial := p.InitialBackoff
+	if initial == 0 {
+		initial = defaultInitialBackoff
+	}
+	maximum := p.MaxBackoff
+	if maximum == 0 {
+		maximum = defaultMaxBackoff
+	}
+
+	delay := initial
+	for i := 0; i < consecutiveRestarts && delay < maximum; i++ {
+		delay *= 2
+	}
+	if delay > maximum {
+		delay = maximum
+	}
+
+	if p.Jitter > 0 {
+		spread := float64(delay) * p.Jitter
+		delay += time.Duration((rand.Float64()*2 - 1) * spread)
+	}
+
+	return delay
+}
+
+// resetAfter returns the run duration after which the consecutive restart count is reset.
+func (p RestartPolicy) resetAfter() time.Duration {
+	if p.ResetAfter == 0 {
+		return defaultResetAfter
+	}
+	return p.ResetAfter
+}
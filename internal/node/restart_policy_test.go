@@ -0,0 +1,80 @@
+package node
+
+import (
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"testing"
+	"time"
+)
+
+func TestRestartPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RestartPolicy
+		wantErr bool
+	}{
+		{"default", RestartPolicy{}, false},
+		{"on-failure with limits", RestartPolicy{Policy: RestartOnFailure, MaxRetries: 3, Jitter: 0.5}, false},
+		{"unknown policy", RestartPolicy{Policy: "sometimes"}, true},
+		{"negative retries", RestartPolicy{Policy: RestartAlways, MaxRetries: -1}, true},
+		{"negative backoff", RestartPolicy{Policy: RestartAlways, InitialBackoff: -time.Second}, true},
+		{"jitter above one", RestartPolicy{Policy: RestartAlways, Jitter: 1.5}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate returned %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRestartPolicyShouldRestart(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RestartPolicy
+		exitCode worker.ExitCode
+		restarts int
+		want     bool
+	}{
+		{"default after failure", RestartPolicy{}, worker.RuntimeErrorExit, 0, false},
+		{"never after failure", RestartPolicy{Policy: RestartNever}, worker.PanicExit, 0, false},
+		{"on-failure after failure", RestartPolicy{Policy: RestartOnFailure}, worker.RuntimeErrorExit, 0, true},
+		{"on-failure after normal exit", RestartPolicy{Policy: RestartOnFailure}, worker.NormalExit, 0, false},
+		{"always after normal exit", RestartPolicy{Policy: RestartAlways}, worker.NormalExit, 0, true},
+		{"below max retries", RestartPolicy{Policy: RestartOnFailure, MaxRetries: 3}, worker.PanicExit, 2, true},
+		{"at max retries", RestartPolicy{Policy: RestartOnFailure, MaxRetries: 3}, worker.PanicExit, 3, false},
+		{"unlimited retries", RestartPolicy{Policy: RestartAlways}, worker.PrematureExit, 100, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.shouldRestart(tt.exitCode, tt.restarts); got != tt.want {
+				t.Errorf("shouldRestart returned %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestartPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RestartPolicy
+		restarts int
+		want     time.Duration
+	}{
+		{"default first restart", RestartPolicy{}, 0, defaultInitialBackoff},
+		{"default doubles", RestartPolicy{}, 2, 4 * defaultInitialBackoff},
+		{"default capped", RestartPolicy{}, 10, defaultMaxBackoff},
+		{"configured doubles", RestartPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}, 3, 800 * time.Millisecond},
+		{"configured capped", RestartPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}, 4, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.backoff(tt.restarts); got != tt.want {
+				t.Errorf("backoff returned %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package node
+
+import (
+	"context"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"sync/atomic"
+	"time"
+)
+
+// ringSlot holds one message of a ring buffer. Its sequence tells producers and the consumer whose turn it is:
+// a slot at position p is free for the producer claiming p when sequence is p, and holds the message for the
+// consumer at p when sequence is p+1.
+type ringSlot struct {
+	sequence atomic.Uint64
+	message  worker.Message
+}
+
+// ringBuffer is a bounded lock-free multi-producer, single-consumer queue of messages. Producers claim positions
+// with a compare-and-swap on the tail; the single consumer advances the head.
+type ringBuffer struct {
+	slots []ringSlot
+	mask  uint64
+
+	_    [56]byte // Keeps tail and head on separate cache lines.
+	tail atomic.Uint64
+	_    [56]byte
+	head atomic.Uint64
+	_    [56]byte
+}
+
+// init allocates slots for at least capacity messages, rounded up to a power of two. The buffer has at least two
+// slots, as a slot's sequence cannot tell a full single slot buffer from an empty one.
+func (b *ringBuffer) init(capacity int) {
+	size := 2
+	for size < capacity {
+		size <<= 1
+	}
+
+	b.slots = make([]ringSlot, size)
+	b.mask = uint64(size - 1)
+	for i := range b.slots {
+		b.slots[i].sequence.Store(uint64(i))
+	}
+}
+
+// enqueue appends the message, reporting false if the buffer is full.
+func (b *ringBuffer) enqueue(message worker.Message) bool {
+	position := b.tail.Load()
+	for {
+		slot := &b.slots[position&b.mask]
+		sequence := slot.sequence.Load()
+		switch difference := int64(sequence - position); {
+		case difference == 0:
+			if b.tail.CompareAndSwap(position, position+1) {
+				slot.message = message
+				slot.sequence.Store(position + 1)
+				return true
+			}
+			position = b.tail.Load()
+		case difference < 0:
+			return false
+		default:
+			position = b.tail.Load()
+		}
+	}
+}
+
+// dequeue removes the oldest message, applying received to it first if set, and reports false if the buffer is
+// empty. Only called by the consumer.
+func (b *ringBuffer) dequeue(received func(message *worker.Message)) (worker.Message, bool) {
+	position := b.head.Load()
+	slot := &b.slots[position&b.mask]
+	if int64(slot.sequence.Load()-(position+1)) < 0 {
+		return worker.Message{}, false
+	}
+
+	// The hook completes the message in its slot, which keeps the returned copy on the stack.
+	if received != nil {
+		received(&slot.message)
+	}
+	message := slot.message
+	slot.message = worker.Message{}
+	slot.sequence.Store(position + b.mask + 1)
+	b.head.Store(position + 1)
+	return message, true
+}
+
+// length returns the number of messages in the buffer.
+func (b *ringBuffer) length() int {
+	return int(b.tail.Load() - b.head.Load())
+}
+
+// ringMailbox is a mailbox backed by a ring buffer for data messages and, if it has a control lane, a smaller one for
+// control messages, which are dequeued first. Waiting is only used when the buffers are empty or full, through channels signalled when a
+// waiter has announced itself.
+type ringMailbox struct {
+	data    ringBuffer
+	control ringBuffer
+
+	options worker.MailboxOptions
+	closed  atomic.Bool
+
+	consumerWaiting  atomic.Bool
+	producersWaiting atomic.Int32
+	ready            chan struct{} // Signalled when a message is queued while the consumer waits.
+	space            chan struct{} // Signalled when a message leaves the ring while producers wait.
+	closing          chan struct{} // Closed when the mailbox is removed.
+
+	// Counters exported as metrics.
+	pushed   atomic.Uint64
+	dropped  atomic.Uint64
+	rejected atomic.Uint64
+
+	// received, if set, is applied to each message as it leaves the ring.
+	received func(message *worker.Message)
+}
+
+// newRingMailbox creates a ring mailbox holding at least capacity data messages and the options' control capacity
+// of control messages, each rounded up to a power of two.
+func newRingMailbox(capacity int, options worker.MailboxOptions) *ringMailbox {
+	r := &ringMailbox{
+		options: options,
+		ready:   make(chan struct{}, 1),
+		space:   make(chan struct{}, 1),
+		closing: make(chan struct{}),
+	}
+	r.data.init(capacity)
+	if options.ControlCapacity > 0 {
+		r.control.init(options.ControlCapacity)
+	}
+	return r
+}
+
+// enqueue appends the message to its lane, reporting false if the lane is full.
+func (r *ringMailbox) enqueue(message worker.Message) bool {
+	lane := &r.data
+	if message.Priority == worker.PriorityControl {
+		lane = &r.control
+	}
+	if !lane.enqueue(message) {
+		return false
+	}
+
+	if r.consumerWaiting.Load() {
+		signal(r.ready)
+	}
+	return true
+}
+
+// dequeue removes the next message, from the control lane if it holds any, reporting false if the ring is empty.
+// Only called by the consumer.
+func (r *ringMailbox) dequeue() (worker.Message, bool) {
+	var message worker.Message
+	var ok bool
+	if r.options.ControlCapacity > 0 {
+		message, ok = r.control.dequeue(r.received)
+	}
+	if !ok {
+		message, ok = r.data.dequeue(r.received)
+	}
+	if !ok {
+		return worker.Message{}, false
+	}
+
+	if r.producersWaiting.Load() > 0 {
+		signal(r.space)
+	}
+	return message, true
+}
+
+// signal wakes a waiter on the channel without blocking.
+func signal(channel chan struct{}) {
+	select {
+	case channel <- struct{}{}:
+	default:
+	}
+}
+
+// push queues a message, applying the overflow policy if the ring is full. Under the default policy, block
+// waits for space without a limit instead of failing. Control messages are not subject to the overflow policy:
+// block waits for space in a full control lane instead of failing.
+func (r *ringMailbox) push(message worker.Message, block bool) error {
+	if message.Priority == worker.PriorityControl && r.options.ControlCapacity == 0 {
+		r.rejected.Add(1)
+		return errNoControlLane
+	}
+
+	var deadline <-chan time.Time
+	for {
+		if r.closed.Load() {
+			return errMailboxClosed
+		}
+		if r.enqueue(message) {
+			r.pushed.Add(1)
+			return nil
+		}
+
+		switch {
+		case message.Priority == worker.PriorityControl:
+			if !block {
+				r.rejected.Add(1)
+				return errControlLaneFull
+			}
+		case r.options.Overflow == worker.OverflowDropNewest:
+			r.pushed.Add(1)
+			r.dropped.Add(1)
+			return nil
+		case r.options.Overflow == worker.OverflowBlock:
+			if deadline == nil && r.options.BlockTimeout > 0 {
+				timer := time.NewTimer(r.options.BlockTimeout)
+				defer timer.Stop()
+				deadline = timer.C
+			}
+		default:
+			if !block {
+				r.rejected.Add(1)
+				return errMailboxFull
+			}
+		}
+
+		// Announce the wait before trying again, so a message leaving the ring in between signals it.
+		r.producersWaiting.Add(1)
+		if r.enqueue(message) {
+			r.producersWaiting.Add(-1)
+			r.pushed.Add(1)
+			return nil
+		}
+		select {
+		case <-r.space:
+		case <-r.closing:
+		case <-deadline:
+			r.producersWaiting.Add(-1)
+			r.rejected.Add(1)
+			return errMailboxTimeout
+		}
+		r.producersWaiting.Add(-1)
+	}
+}
+
+// Receive waits for the next message. It implements worker.Inbox.
+func (r *ringMailbox) Receive(ctx context.Context) (worker.Message, error) {
+	for {
+		if r.closed.Load() {
+			return worker.Message{}, worker.ErrInboxClosed
+		}
+		if message, ok := r.dequeue(); ok {
+			return message, nil
+		}
+
+		// Announce the wait before checking again, so a message queued in between signals it.
+		r.consumerWaiting.Store(true)
+		if message, ok := r.dequeue(); ok {
+			r.consumerWaiting.Store(false)
+			return message, nil
+		}
+		select {
+		case <-r.ready:
+		case <-r.closing:
+		case <-ctx.Done():
+			r.consumerWaiting.Store(false)
+			return worker.Message{}, ctx.Err()
+		}
+		r.consumerWaiting.Store(false)
+	}
+}
+
+// TryReceive returns the next message without waiting. It implements worker.Inbox.
+func (r *ringMailbox) TryReceive() (worker.Message, bool) {
+	if r.closed.Load() {
+		return worker.Message{}, false
+	}
+	return r.dequeue()
+}
+
+// run delivers the ring's messages to a channel until the mailbox is removed, for workers receiving from it
+// through CreateMailbox rather than an inbox.
+func (r *ringMailbox) run(out chan<- any, done func()) {
+	defer done()
+	defer close(out)
+
+	for {
+		message, err := r.Receive(context.Background())
+		if err != nil {
+			return
+		}
+		select {
+		case out <- message:
+		case <-r.closing:
+			return
+		}
+	}
+}
+
+// length returns the number of messages in both lanes.
+func (r *ringMailbox) length() int {
+	return r.data.length() + r.control.length()
+}
+
+// stats returns the ring's counters and current depth.
+func (r *ringMailbox) stats() MailboxStats {
+	return MailboxStats{
+		Capacity:     len(r.data.slots),
+		Depth:        r.length(),
+		ControlDepth: r.control.length(),
+		Overflow:     r.options.Overflow,
+		Backend:      worker.BackendRing,
+		Pushed:       r.pushed.Load(),
+		Dropped:      r.dropped.Load(),
+		Rejected:     r.rejected.Load(),
+	}
+}
+
+// close stops the ring, waking any waiting producer or consumer. Pushes after close fail.
+func (r *ringMailbox) close() {
+	if r.closed.CompareAndSwap(false, true) {
+		close(r.closing)
+	}
+}
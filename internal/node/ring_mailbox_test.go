@@ -0,0 +1,210 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/google/uuid"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// producedMessage identifies a message by its producer and its index among that producer's messages.
+type producedMessage struct {
+	producer int
+	index    int
+}
+
+func TestRingBufferConcurrentProducers(t *testing.T) {
+	const producers = 8
+	const perProducer = 20000
+
+	var buffer ringBuffer
+	buffer.init(64)
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				for !buffer.enqueue(worker.Message{Payload: producedMessage{producer: p, index: i}}) {
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+
+	// Every message must arrive exactly once, and each producer's messages in the order they were enqueued.
+	next := make([]int, producers)
+	for received := 0; received < producers*perProducer; {
+		message, ok := buffer.dequeue(nil)
+		if !ok {
+			runtime.Gosched()
+			continue
+		}
+		received++
+
+		produced, ok := message.Payload.(producedMessage)
+		if !ok {
+			t.Fatalf("dequeued payload %#v, want a producedMessage", message.Payload)
+		}
+		if produced.index != next[produced.producer] {
+			t.Fatalf("producer %d: dequeued message %d, want %d", produced.producer, produced.index, next[produced.producer])
+		}
+		next[produced.producer]++
+	}
+	wg.Wait()
+
+	if message, ok := buffer.dequeue(nil); ok {
+		t.Fatalf("dequeued %#v from a drained buffer", message)
+	}
+	if length := buffer.length(); length != 0 {
+		t.Fatalf("drained buffer has length %d", length)
+	}
+}
+
+func BenchmarkMailboxQueueChannel(b *testing.B) {
+	benchmarkMailboxProducers(b, false)
+}
+
+func BenchmarkMailboxQueueInbox(b *testing.B) {
+	benchmarkMailboxProducers(b, true)
+}
+
+func BenchmarkMailboxQueueControlLaneChannel(b *testing.B) {
+	benchmarkMailboxProducers(b, false, worker.WithControlCapacity(16))
+}
+
+func BenchmarkMailboxRingChannel(b *testing.B) {
+	benchmarkMailboxProducers(b, false, worker.WithBackend(worker.BackendRing))
+}
+
+func BenchmarkMailboxRingInbox(b *testing.B) {
+	benchmarkMailboxProducers(b, true, worker.WithBackend(worker.BackendRing))
+}
+
+// BenchmarkChannelBaseline passes the same messages through a bare buffered channel, the baseline the mailboxes are
+// measured against.
+func BenchmarkChannelBaseline(b *testing.B) {
+	for _, producers := range []int{1, 4} {
+		b.Run(fmt.Sprintf("producers=%d", producers), func(b *testing.B) {
+			channel := make(chan any, 1024)
+			message := worker.Message{Tag: "depth", Payload: 1.0}
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			consumed := make(chan struct{})
+			go func() {
+				for i := 0; i < b.N; i++ {
+					<-channel
+				}
+				close(consumed)
+			}()
+
+			var wg sync.WaitGroup
+			for p := 0; p < producers; p++ {
+				wg.Add(1)
+				count := producerCount(b.N, producers, p)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < count; i++ {
+						channel <- message
+					}
+				}()
+			}
+			wg.Wait()
+
+			<-consumed
+			b.StopTimer()
+		})
+	}
+}
+
+// producerCount returns the number of messages producer p sends when n messages are split between the producers.
+func producerCount(n, producers, p int) int {
+	count := n / producers
+	if p < n%producers {
+		count++
+	}
+	return count
+}
+
+// benchmarkMailboxProducers runs benchmarkMailbox with one and with several concurrent producers.
+func benchmarkMailboxProducers(b *testing.B, inbox bool, opts ...worker.MailboxOption) {
+	for _, producers := range []int{1, 4} {
+		b.Run(fmt.Sprintf("producers=%d", producers), func(b *testing.B) {
+			benchmarkMailbox(b, inbox, producers, opts...)
+		})
+	}
+}
+
+// benchmarkMailbox pushes b.N messages through a dispatcher mailbox created with the options, split between the
+// producers, and waits for a single consumer to receive them from the mailbox's channel or, if inbox is set, its
+// inbox.
+func benchmarkMailbox(b *testing.B, inbox bool, producers int, opts ...worker.MailboxOption) {
+	dispatcher := NewDispatcher()
+	mailboxUUID := uuid.New()
+
+	var receive func() error
+	if inbox {
+		mailboxInbox, err := dispatcher.CreateInbox(mailboxUUID, 1024, opts...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		receive = func() error {
+			_, err := mailboxInbox.Receive(context.Background())
+			return err
+		}
+	} else {
+		channel, err := dispatcher.CreateMailbox(mailboxUUID, 1024, opts...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		receive = func() error {
+			if _, ok := <-channel; !ok {
+				return worker.ErrInboxClosed
+			}
+			return nil
+		}
+	}
+	defer dispatcher.Wait()
+	defer dispatcher.RemoveMailbox(mailboxUUID)
+
+	message := worker.Message{Tag: "depth", Payload: 1.0}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	consumed := make(chan error, 1)
+	go func() {
+		for i := 0; i < b.N; i++ {
+			if err := receive(); err != nil {
+				consumed <- err
+				return
+			}
+		}
+		consumed <- nil
+	}()
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		count := producerCount(b.N, producers, p)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				if err := dispatcher.PushMessageBlocking(mailboxUUID, message); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := <-consumed; err != nil {
+		b.Fatal(err)
+	}
+	b.StopTimer()
+}
@@ -0,0 +1,217 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/google/uuid"
+	"sort"
+	"time"
+)
+
+// drainPollInterval is how often mailbox depths are checked while waiting for them to drain.
+const drainPollInterval = 10 * time.Millisecond
+
+// WorkerShutdownResult reports how a worker exited during node shutdown. Stage is the order in which the worker
+// was stopped, producers first. Clean is true if the worker exited normally before the deadline.
+type WorkerShutdownResult struct {
+	UUID     uuid.UUID       `json:"uuid"`
+	Type     string          `json:"type"`
+	Stage    int             `json:"stage"`
+	Clean    bool            `json:"clean"`
+	ExitCode worker.ExitCode `json:"exit_code"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// Shutdown stops every active worker, upstream before downstream, and rejects any further tasks.
+//
+// Workers are stopped in stages derived from their configs: a worker is stopped once every worker sending to its
+// mailboxes has stopped and its mailboxes have drained, so producers such as websocket workers stop first and the
+// messages they produced reach their consumers. Once ctx is done, draining stops and the remaining workers are
+// stopped without waiting. It returns a result for every worker that was active, and ctx.Err() if the deadline
+// was reached.
+func (n *Node) Shutdown(ctx context.Context) ([]WorkerShutdownResult, error) {
+	n.traces.retainAll()
+
+	n.mu.Lock()
+	n.shuttingDown = true
+	active := make(map[uuid.UUID]*WorkerContainer)
+	for workerUUID, wc := range n.workers {
+		if wc.status.isActive {
+			active[workerUUID] = wc
+		}
+	}
+	stages := n.shutdownStages(active)
+	n.mu.Unlock()
+
+	var results []WorkerShutdownResult
+	for stageIndex, stage := range stages {
+		n.drainMailboxes(ctx, stage)
+
+		n.mu.Lock()
+		for _, wc := range stage {
+			if wc.cancelFunc != nil {
+				wc.cancelFunc()
+			}
+		}
+		n.mu.Unlock()
+
+		for _, wc := range stage {
+			results = append(results, n.awaitShutdown(ctx, wc, stageIndex))
+		}
+	}
+
+	// Every mailbox is removed once its owner exits, so this only blocks on workers that missed the deadline.
+	drained := make(chan struct{})
+	go func() {
+		n.dispatcher.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return results, nil
+	case <-ctx.Done():
+		return results, ctx.Err()
+	}
+}
+
+// shutdownStages orders the active workers into stages, each holding the workers whose upstream workers are all in
+// earlier stages. Workers in a cycle are stopped together. The node lock must be held.
+func (n *Node) shutdownStages(active map[uuid.UUID]*WorkerContainer) [][]*WorkerContainer {
+	owners := make(map[uuid.UUID]uuid.UUID)
+	outputs := make(map[uuid.UUID][]uuid.UUID)
+	for workerUUID, wc := range active {
+		if wc.services != nil {
+			for _, mailboxUUID := range wc.services.ownedMailboxes() {
+				owners[mailboxUUID] = workerUUID
+			}
+		}
+
+		inspector, ok := wc.worker.(worker.ConfigInspector)
+		if !ok {
+			continue
+		}
+		info, err := inspector.InspectConfig(wc.rawConfig)
+		if err != nil {
+			continue
+		}
+		for _, mailboxUUID := range info.InputMailboxes {
+			owners[mailboxUUID] = workerUUID
+		}
+		outputs[workerUUID] = info.OutputMailboxes
+	}
+
+	upstream := make(map[uuid.UUID]map[uuid.UUID]bool)
+	for workerUUID, mailboxUUIDs := range outputs {
+		for _, mailboxUUID := range mailboxUUIDs {
+			owner, exists := owners[mailboxUUID]
+			if !exists || owner == workerUUID {
+				continue
+			}
+			if upstream[owner] == nil {
+				upstream[owner] = make(map[uuid.UUID]bool)
+			}
+			upstream[owner][workerUUID] = true
+		}
+	}
+
+	remaining := make(map[uuid.UUID]*WorkerContainer, len(active))
+	for workerUUID, wc := range active {
+		remaining[workerUUID] = wc
+	}
+
+	var stages [][]*WorkerContainer
+	for len(remaining) > 0 {
+		var stage []*WorkerContainer
+		for workerUUID, wc := range remaining {
+			blocked := false
+			for upstreamUUID := range upstream[workerUUID] {
+				if _, exists := remaining[upstreamUUID]; exists {
+					blocked = true
+					break
+				}
+			}
+			if !blocked {
+				stage = append(stage, wc)
+			}
+		}
+
+		// Only workers in a cycle remain, and none of them can be stopped before the others.
+		if len(stage) == 0 {
+			for _, wc := range remaining {
+				stage = append(stage, wc)
+			}
+		}
+
+		sort.Slice(stage, func(i, j int) bool {
+			return stage[i].uuid.String() < stage[j].uuid.String()
+		})
+		for _, wc := range stage {
+			delete(remaining, wc.uuid)
+		}
+		stages = append(stages, stage)
+	}
+
+	return stages
+}
+
+// drainMailboxes waits until the mailboxes owned by the workers are empty, or until ctx is done.
+func (n *Node) drainMailboxes(ctx context.Context, workers []*WorkerContainer) {
+	var mailboxUUIDs []uuid.UUID
+	n.mu.Lock()
+	for _, wc := range workers {
+		if wc.services != nil {
+			mailboxUUIDs = append(mailboxUUIDs, wc.services.ownedMailboxes()...)
+		}
+	}
+	n.mu.Unlock()
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		pending := 0
+		for _, mailboxUUID := range mailboxUUIDs {
+			pending += n.dispatcher.GetMailboxLength(mailboxUUID)
+		}
+		if pending == 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// awaitShutdown waits for a stopped worker to exit, or until ctx is done, and reports how it exited.
+func (n *Node) awaitShutdown(ctx context.Context, wc *WorkerContainer, stage int) WorkerShutdownResult {
+	result := WorkerShutdownResult{
+		UUID:  wc.uuid,
+		Type:  wc.workerType,
+		Stage: stage,
+	}
+
+	select {
+	case <-wc.done:
+	case <-ctx.Done():
+		result.Error = "worker did not exit before the shutdown deadline"
+		return result
+	}
+
+	n.mu.Lock()
+	result.ExitCode = wc.status.exitCode
+	if wc.status.error != nil {
+		result.Error = wc.status.error.Error()
+	}
+	n.mu.Unlock()
+
+	result.Clean = result.ExitCode == worker.NormalExit
+	if !result.Clean {
+		fmt.Printf("Worker %s did not exit cleanly during shutdown: %s\n", wc.uuid, result.Error)
+	}
+	return result
+}
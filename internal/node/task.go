@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	"gopkg.in/yaml.v3"
+	"time"
 )
 
-// Task definition
+// Task definition. An atomic task rolls back the workers it created or started if any of its instructions fail.
 type Task struct {
+	Atomic       bool          `yaml:"atomic"`
 	Instructions []Instruction `yaml:"instructions"`
 }
 
@@ -17,6 +19,30 @@ type Instruction struct {
 	Args any    `yaml:"args"`
 }
 
+// InstructionStatus describes the outcome of an instruction within a processed task.
+type InstructionStatus string
+
+const (
+	InstructionApplied InstructionStatus = "applied"
+	InstructionFailed  InstructionStatus = "failed"
+	InstructionSkipped InstructionStatus = "skipped"
+	// InstructionRejected marks an instruction that failed validation, so the task was not applied.
+	InstructionRejected InstructionStatus = "rejected"
+	// InstructionRolledBack marks an applied instruction that was undone after a later instruction of an atomic
+	// task failed.
+	InstructionRolledBack InstructionStatus = "rolled_back"
+	// InstructionRollbackFailed marks an applied instruction that could not be undone.
+	InstructionRollbackFailed InstructionStatus = "rollback_failed"
+)
+
+// InstructionResult records the outcome of a single instruction within a processed task.
+type InstructionResult struct {
+	Index  int               `json:"index"`
+	Type   string            `json:"type"`
+	Status InstructionStatus `json:"status"`
+	Error  string            `json:"error,omitempty"`
+}
+
 type CreateWorkerInstructionArgs struct {
 	WorkerType string    `yaml:"worker_type"`
 	WorkerUUID uuid.UUID `yaml:"worker_uuid"`
@@ -27,14 +53,26 @@ type RemoveWorkerInstructionArgs struct {
 }
 
 type StartWorkerInstructionArgs struct {
-	WorkerUUID      uuid.UUID `yaml:"worker_uuid"`
-	WorkerRawConfig []byte    `yaml:"worker_raw_config"`
+	WorkerUUID      uuid.UUID     `yaml:"worker_uuid"`
+	WorkerRawConfig []byte        `yaml:"worker_raw_config"`
+	RestartPolicy   RestartPolicy `yaml:"restart_policy"`
+	// WaitReady holds the instruction until the worker reports it is ready, failing and stopping the worker if it
+	// is not ready within ReadyTimeout, or DefaultReadyTimeout if that is zero.
+	WaitReady    bool          `yaml:"wait_ready"`
+	ReadyTimeout time.Duration `yaml:"ready_timeout"`
 }
 
 type StopWorkerInstructionArgs struct {
 	WorkerUUID uuid.UUID `yaml:"worker_uuid"`
 }
 
+// ReconfigureWorkerInstructionArgs gives an active worker a new raw config, applied while it runs if the worker
+// supports it, or by restarting it otherwise.
+type ReconfigureWorkerInstructionArgs struct {
+	WorkerUUID      uuid.UUID `yaml:"worker_uuid"`
+	WorkerRawConfig []byte    `yaml:"worker_raw_config"`
+}
+
 func parseTaskFromYaml(yamlBytes []byte) (Task, error) {
 	var root yaml.Node
 	if err := yaml.Unmarshal(yamlBytes, &root); err != nil {
@@ -47,13 +85,18 @@ func parseTaskFromYaml(yamlBytes []byte) (Task, error) {
 	}
 	doc := root.Content[0]
 
-	// Find the "instructions" node.
+	// Find the "instructions" node and the task options.
+	var task Task
 	var instructionsNode *yaml.Node
 	for i := 0; i < len(doc.Content); i += 2 {
 		keyNode := doc.Content[i]
-		if keyNode.Value == "instructions" {
+		switch keyNode.Value {
+		case "instructions":
 			instructionsNode = doc.Content[i+1]
-			break
+		case "atomic":
+			if err := doc.Content[i+1].Decode(&task.Atomic); err != nil {
+				return Task{}, fmt.Errorf("failed to decode atomic: %w", err)
+			}
 		}
 	}
 	if instructionsNode == nil {
@@ -63,7 +106,6 @@ func parseTaskFromYaml(yamlBytes []byte) (Task, error) {
 		return Task{}, fmt.Errorf("instructions is not a sequence")
 	}
 
-	var task Task
 	// Process each instruction.
 	for _, instrNode := range instructionsNode.Content {
 		var instrType string
@@ -112,13 +154,22 @@ func parseTaskFromYaml(yamlBytes []byte) (Task, error) {
 		case "start_worker":
 			// Use a temporary struct to capture the raw YAML node.
 			type tempStartArgs struct {
-				WorkerUUID      uuid.UUID `yaml:"worker_uuid"`
-				WorkerRawConfig yaml.Node `yaml:"worker_raw_config"`
+				WorkerUUID      uuid.UUID     `yaml:"worker_uuid"`
+				WorkerRawConfig yaml.Node     `yaml:"worker_raw_config"`
+				RestartPolicy   RestartPolicy `yaml:"restart_policy"`
+				WaitReady       bool          `yaml:"wait_ready"`
+				ReadyTimeout    time.Duration `yaml:"ready_timeout"`
 			}
 			var tempArgs tempStartArgs
 			if err := argsNode.Decode(&tempArgs); err != nil {
 				return Task{}, fmt.Errorf("failed to decode start_worker args: %w", err)
 			}
+			if err := tempArgs.RestartPolicy.validate(); err != nil {
+				return Task{}, fmt.Errorf("invalid restart_policy for worker %s: %w", tempArgs.WorkerUUID, err)
+			}
+			if tempArgs.ReadyTimeout < 0 {
+				return Task{}, fmt.Errorf("invalid ready_timeout for worker %s: must not be negative", tempArgs.WorkerUUID)
+			}
 			// Re-marshal the worker_raw_config node back into YAML bytes.
 			rawConfigBytes, err := yaml.Marshal(&tempArgs.WorkerRawConfig)
 			if err != nil {
@@ -127,9 +178,30 @@ func parseTaskFromYaml(yamlBytes []byte) (Task, error) {
 			startArgs := StartWorkerInstructionArgs{
 				WorkerUUID:      tempArgs.WorkerUUID,
 				WorkerRawConfig: rawConfigBytes,
+				RestartPolicy:   tempArgs.RestartPolicy,
+				WaitReady:       tempArgs.WaitReady,
+				ReadyTimeout:    tempArgs.ReadyTimeout,
 			}
 			decodedArgs = startArgs
 
+		case "reconfigure_worker":
+			type tempReconfigureArgs struct {
+				WorkerUUID      uuid.UUID `yaml:"worker_uuid"`
+				WorkerRawConfig yaml.Node `yaml:"worker_raw_config"`
+			}
+			var tempArgs tempReconfigureArgs
+			if err := argsNode.Decode(&tempArgs); err != nil {
+				return Task{}, fmt.Errorf("failed to decode reconfigure_worker args: %w", err)
+			}
+			rawConfigBytes, err := yaml.Marshal(&tempArgs.WorkerRawConfig)
+			if err != nil {
+				return Task{}, fmt.Errorf("failed to marshal worker_raw_config: %w", err)
+			}
+			decodedArgs = ReconfigureWorkerInstructionArgs{
+				WorkerUUID:      tempArgs.WorkerUUID,
+				WorkerRawConfig: rawConfigBytes,
+			}
+
 		default:
 			// For any unknown instruction type, decode the args into a generic map.
 			var genericArgs map[string]interface{}
@@ -147,3 +219,83 @@ func parseTaskFromYaml(yamlBytes []byte) (Task, error) {
 
 	return task, nil
 }
+
+// MarshalTask encodes a task into the YAML format accepted by ParseTask.
+func MarshalTask(task Task) ([]byte, error) {
+	type rawInstruction struct {
+		Type string `yaml:"type"`
+		Args any    `yaml:"args"`
+	}
+	type rawStartArgs struct {
+		WorkerUUID      uuid.UUID     `yaml:"worker_uuid"`
+		WorkerRawConfig *yaml.Node    `yaml:"worker_raw_config"`
+		RestartPolicy   RestartPolicy `yaml:"restart_policy,omitempty"`
+		WaitReady       bool          `yaml:"wait_ready,omitempty"`
+		ReadyTimeout    time.Duration `yaml:"ready_timeout,omitempty"`
+	}
+	type rawReconfigureArgs struct {
+		WorkerUUID      uuid.UUID  `yaml:"worker_uuid"`
+		WorkerRawConfig *yaml.Node `yaml:"worker_raw_config"`
+	}
+
+	document := struct {
+		Atomic       bool             `yaml:"atomic,omitempty"`
+		Instructions []rawInstruction `yaml:"instructions"`
+	}{
+		Atomic:       task.Atomic,
+		Instructions: make([]rawInstruction, 0, len(task.Instructions)),
+	}
+
+	for _, instruction := range task.Instructions {
+		args := instruction.Args
+
+		// The raw config is carried as YAML bytes, so it is embedded as a node rather than a binary string.
+		switch typedArgs := args.(type) {
+		case StartWorkerInstructionArgs:
+			rawConfig, err := rawConfigNode(typedArgs.WorkerUUID, typedArgs.WorkerRawConfig)
+			if err != nil {
+				return nil, err
+			}
+			args = rawStartArgs{
+				WorkerUUID:      typedArgs.WorkerUUID,
+				WorkerRawConfig: rawConfig,
+				RestartPolicy:   typedArgs.RestartPolicy,
+				WaitReady:       typedArgs.WaitReady,
+				ReadyTimeout:    typedArgs.ReadyTimeout,
+			}
+		case ReconfigureWorkerInstructionArgs:
+			rawConfig, err := rawConfigNode(typedArgs.WorkerUUID, typedArgs.WorkerRawConfig)
+			if err != nil {
+				return nil, err
+			}
+			args = rawReconfigureArgs{
+				WorkerUUID:      typedArgs.WorkerUUID,
+				WorkerRawConfig: rawConfig,
+			}
+		}
+
+		document.Instructions = append(document.Instructions, rawInstruction{
+			Type: instruction.Type,
+			Args: args,
+		})
+	}
+
+	yamlBytes, err := yaml.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal task: %w", err)
+	}
+	return yamlBytes, nil
+}
+
+// rawConfigNode parses a worker's raw config into the YAML node embedded in a marshalled task, or nil if it is
+// empty.
+func rawConfigNode(workerUUID uuid.UUID, rawConfigBytes []byte) (*yaml.Node, error) {
+	var rawConfig yaml.Node
+	if err := yaml.Unmarshal(rawConfigBytes, &rawConfig); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal worker_raw_config of worker %s: %w", workerUUID, err)
+	}
+	if len(rawConfig.Content) == 0 {
+		return nil, nil
+	}
+	return rawConfig.Content[0], nil
+}
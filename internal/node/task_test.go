@@ -0,0 +1,135 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/google/uuid"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// idleWorker reports ready and runs until stopped.
+type idleWorker struct{}
+
+func (idleWorker) Run(ctx context.Context, _ any, services worker.Services) (worker.ExitCode, error) {
+	services.Ready()
+	<-ctx.Done()
+	return worker.NormalExit, nil
+}
+
+// failingWorker exits with an error before reporting ready.
+type failingWorker struct{}
+
+func (failingWorker) Run(context.Context, any, worker.Services) (worker.ExitCode, error) {
+	return worker.RuntimeErrorExit, fmt.Errorf("failed to start")
+}
+
+// newTestNode creates a node running idle and failing workers, shut down when the test ends.
+func newTestNode(t *testing.T) *Node {
+	t.Helper()
+	factory := worker.NewFactory()
+	factory.RegisterWorkerCreationFunction("Idle", func() worker.Worker { return idleWorker{} })
+	factory.RegisterWorkerCreationFunction("Failing", func() worker.Worker { return failingWorker{} })
+
+	n := NewNode(factory)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, _ = n.Shutdown(ctx)
+	})
+	return n
+}
+
+func TestProcessTaskRollback(t *testing.T) {
+	tests := []struct {
+		name        string
+		atomic      bool
+		wantStatus  []InstructionStatus
+		wantWorkers map[string]bool // Worker type to whether it is active.
+	}{
+		{
+			name:   "atomic task rolls back applied instructions",
+			atomic: true,
+			wantStatus: []InstructionStatus{
+				InstructionRolledBack, InstructionRolledBack, InstructionRolledBack, InstructionFailed, InstructionSkipped,
+			},
+			wantWorkers: map[string]bool{},
+		},
+		{
+			name:   "non-atomic task keeps applied instructions",
+			atomic: false,
+			wantStatus: []InstructionStatus{
+				InstructionApplied, InstructionApplied, InstructionApplied, InstructionFailed, InstructionSkipped,
+			},
+			wantWorkers: map[string]bool{"Idle": true, "Failing": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNode(t)
+			idle, failing := uuid.New(), uuid.New()
+			task := Task{Atomic: tt.atomic, Instructions: []Instruction{
+				{Type: "create_worker", Args: CreateWorkerInstructionArgs{WorkerType: "Idle", WorkerUUID: idle}},
+				{Type: "start_worker", Args: StartWorkerInstructionArgs{WorkerUUID: idle, WaitReady: true}},
+				{Type: "create_worker", Args: CreateWorkerInstructionArgs{WorkerType: "Failing", WorkerUUID: failing}},
+				{Type: "start_worker", Args: StartWorkerInstructionArgs{WorkerUUID: failing, WaitReady: true}},
+				{Type: "stop_worker", Args: StopWorkerInstructionArgs{WorkerUUID: idle}},
+			}}
+
+			results, err := n.ProcessTask(task)
+			if err == nil {
+				t.Fatal("expected the task to fail")
+			}
+
+			var statuses []InstructionStatus
+			for _, result := range results {
+				statuses = append(statuses, result.Status)
+			}
+			if !reflect.DeepEqual(statuses, tt.wantStatus) {
+				t.Errorf("instruction statuses %v, want %v", statuses, tt.wantStatus)
+			}
+
+			workers := make(map[string]bool)
+			for _, info := range n.ListWorkers() {
+				workers[info.Type] = info.Active
+			}
+			if !reflect.DeepEqual(workers, tt.wantWorkers) {
+				t.Errorf("workers left %v, want %v", workers, tt.wantWorkers)
+			}
+		})
+	}
+}
+
+func TestProcessTaskRejectsInvalidTask(t *testing.T) {
+	n := newTestNode(t)
+	task := Task{Instructions: []Instruction{
+		{Type: "create_worker", Args: CreateWorkerInstructionArgs{WorkerType: "Idle", WorkerUUID: uuid.New()}},
+		{Type: "start_worker", Args: StartWorkerInstructionArgs{WorkerUUID: uuid.New()}},
+		{Type: "create_worker", Args: CreateWorkerInstructionArgs{WorkerType: "Unknown", WorkerUUID: uuid.New()}},
+	}}
+
+	results, err := n.ProcessTask(task)
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("returned %v, want a *ValidationError", err)
+	}
+
+	var rejected []int
+	for _, issue := range validationErr.Issues {
+		rejected = append(rejected, issue.Instruction)
+	}
+	sort.Ints(rejected)
+	if want := []int{1, 2}; !reflect.DeepEqual(rejected, want) {
+		t.Errorf("issues for instructions %v, want %v", rejected, want)
+	}
+	if results[0].Status != InstructionSkipped || results[1].Status != InstructionRejected {
+		t.Errorf("statuses %s and %s, want %s and %s", results[0].Status, results[1].Status, InstructionSkipped, InstructionRejected)
+	}
+	if workers := n.ListWorkers(); len(workers) != 0 {
+		t.Errorf("rejected task left %d worker(s)", len(workers))
+	}
+}
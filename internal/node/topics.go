@@ -0,0 +1,159 @@
+package node
+
+import (
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/metrics"
+	"github.com/google/uuid"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// TopicInfo describes a topic, its payload type and its current subscribers.
+type TopicInfo struct {
+	Name        string      `json:"name"`
+	PayloadType string      `json:"payload_type,omitempty"`
+	Subscribers []uuid.UUID `json:"subscribers"`
+	Published   uint64      `json:"published"`
+}
+
+// topic holds the subscribers of a named topic, keyed by mailbox with the UUID of the worker that subscribed it.
+type topic struct {
+	payloadType reflect.Type
+	subscribers map[uuid.UUID]uuid.UUID
+	published   uint64
+}
+
+// topicRegistry tracks the node's topics. Topics exist from their first publish or subscription.
+type topicRegistry struct {
+	mu     sync.Mutex
+	topics map[string]*topic
+}
+
+func newTopicRegistry() *topicRegistry {
+	return &topicRegistry{topics: make(map[string]*topic)}
+}
+
+// get returns the named topic, creating it if needed. The registry lock must be held.
+func (r *topicRegistry) get(name string) *topic {
+	t, exists := r.topics[name]
+	if !exists {
+		t = &topic{subscribers: make(map[uuid.UUID]uuid.UUID)}
+		r.topics[name] = t
+	}
+	return t
+}
+
+// publish checks the payload against the topic's type, fixing the type on the first publish, and returns the
+// mailboxes subscribed to the topic.
+func (r *topicRegistry) publish(name string, payload any) ([]uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := r.get(name)
+	if payloadType := reflect.TypeOf(payload); payloadType != nil {
+		if t.payloadType == nil {
+			t.payloadType = payloadType
+		} else if t.payloadType != payloadType {
+			return nil, fmt.Errorf("topic %q carries payloads of type %v, not %v", name, t.payloadType, payloadType)
+		}
+	}
+	t.published++
+
+	subscribers := make([]uuid.UUID, 0, len(t.subscribers))
+	for mailboxUUID := range t.subscribers {
+		subscribers = append(subscribers, mailboxUUID)
+	}
+	return subscribers, nil
+}
+
+// subscribe adds the mailbox, owned by the given worker, to the topic's subscribers.
+func (r *topicRegistry) subscribe(name string, mailboxUUID uuid.UUID, workerUUID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := r.get(name)
+	if _, exists := t.subscribers[mailboxUUID]; exists {
+		return fmt.Errorf("mailbox %s is already subscribed to topic %q", mailboxUUID, name)
+	}
+	t.subscribers[mailboxUUID] = workerUUID
+	return nil
+}
+
+// unsubscribe removes the mailbox from the topic's subscribers.
+func (r *topicRegistry) unsubscribe(name string, mailboxUUID uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if t, exists := r.topics[name]; exists {
+		delete(t.subscribers, mailboxUUID)
+	}
+}
+
+// unsubscribeMailbox removes the mailbox from every topic, e.g. once it has been removed.
+func (r *topicRegistry) unsubscribeMailbox(mailboxUUID uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, t := range r.topics {
+		delete(t.subscribers, mailboxUUID)
+	}
+}
+
+// unsubscribeWorker removes every subscription made by the worker.
+func (r *topicRegistry) unsubscribeWorker(workerUUID uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, t := range r.topics {
+		for mailboxUUID, subscriber := range t.subscribers {
+			if subscriber == workerUUID {
+				delete(t.subscribers, mailboxUUID)
+			}
+		}
+	}
+}
+
+// Topics returns every topic on the node, sorted by name.
+func (n *Node) Topics() []TopicInfo {
+	n.topics.mu.Lock()
+	topics := make([]TopicInfo, 0, len(n.topics.topics))
+	for name, t := range n.topics.topics {
+		info := TopicInfo{
+			Name:        name,
+			Subscribers: make([]uuid.UUID, 0, len(t.subscribers)),
+			Published:   t.published,
+		}
+		if t.payloadType != nil {
+			info.PayloadType = t.payloadType.String()
+		}
+		for mailboxUUID := range t.subscribers {
+			info.Subscribers = append(info.Subscribers, mailboxUUID)
+		}
+		sort.Slice(info.Subscribers, func(i, j int) bool {
+			return info.Subscribers[i].String() < info.Subscribers[j].String()
+		})
+		topics = append(topics, info)
+	}
+	n.topics.mu.Unlock()
+
+	sort.Slice(topics, func(i, j int) bool {
+		return topics[i].Name < topics[j].Name
+	})
+	return topics
+}
+
+// collectTopics writes the subscriber count and publish counter of every topic.
+func (n *Node) collectTopics(w *metrics.Writer) {
+	topics := n.Topics()
+
+	w.Header("tessera_topic_subscribers", "Mailboxes subscribed to the topic.", metrics.GaugeType)
+	for _, info := range topics {
+		w.Sample("tessera_topic_subscribers", float64(len(info.Subscribers)), "topic", info.Name)
+	}
+
+	w.Header("tessera_topic_published_total", "Messages published to the topic.", metrics.CounterType)
+	for _, info := range topics {
+		w.Sample("tessera_topic_published_total", float64(info.Published), "topic", info.Name)
+	}
+}
@@ -0,0 +1,204 @@
+package node
+
+import (
+	"github.com/PhillipMichelsen/Tessera/internal/metrics"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TraceEdgeKind distinguishes the latencies aggregated by the trace collector.
+type TraceEdgeKind string
+
+const (
+	// TraceHop is the latency of a single hop: from a worker's send to the message leaving the destination
+	// mailbox's queue.
+	TraceHop TraceEdgeKind = "hop"
+	// TraceOrigin is the age of a message when it leaves a mailbox's queue, measured from its creation by the worker
+	// that started its trace, across every hop in between.
+	TraceOrigin TraceEdgeKind = "origin"
+)
+
+// TraceEdge summarizes the latencies of messages from a worker to a mailbox.
+type TraceEdge struct {
+	Kind        TraceEdgeKind `json:"kind"`
+	FromWorker  uuid.UUID     `json:"from_worker"`
+	FromType    string        `json:"from_type,omitempty"`
+	ToMailbox   uuid.UUID     `json:"to_mailbox"`
+	ToType      string        `json:"to_type,omitempty"`
+	Count       uint64        `json:"count"`
+	MeanSeconds float64       `json:"mean_seconds"`
+	P50Seconds  float64       `json:"p50_seconds"`
+	P90Seconds  float64       `json:"p90_seconds"`
+	P99Seconds  float64       `json:"p99_seconds"`
+
+	snapshot metrics.HistogramSnapshot
+}
+
+// traceEdgeKey identifies the latencies from a worker to a mailbox.
+type traceEdgeKey struct {
+	kind       TraceEdgeKind
+	fromWorker uuid.UUID
+	toMailbox  uuid.UUID
+}
+
+// traceCollector aggregates the spans of messages as they leave mailboxes into per-edge latency histograms.
+type traceCollector struct {
+	mu         sync.Mutex
+	histograms map[traceEdgeKey]*metrics.Histogram
+	retain     bool // Set once the node shuts down, so the final dump covers every mailbox.
+}
+
+func newTraceCollector() *traceCollector {
+	return &traceCollector{histograms: make(map[traceEdgeKey]*metrics.Histogram)}
+}
+
+// received completes the open span of a message leaving the mailbox's queue and records its latencies.
+// It returns the message with its span completed.
+func (c *traceCollector) received(mailboxUUID uuid.UUID, received any, now time.Time) any {
+	message, ok := received.(worker.Message)
+	if !ok {
+		return received
+	}
+
+	c.finish(mailboxUUID, &message, now)
+	return message
+}
+
+// finish completes the open span of a message leaving the mailbox's queue in place and records its latencies.
+func (c *traceCollector) finish(mailboxUUID uuid.UUID, message *worker.Message, now time.Time) {
+	span, ok := message.FinishSpan(mailboxUUID, now)
+	if !ok {
+		return
+	}
+
+	c.observe(traceEdgeKey{kind: TraceHop, fromWorker: span.Worker, toMailbox: mailboxUUID}, span.Latency())
+	if !message.CreatedAt.IsZero() && message.Origin != uuid.Nil {
+		c.observe(traceEdgeKey{kind: TraceOrigin, fromWorker: message.Origin, toMailbox: mailboxUUID}, now.Sub(message.CreatedAt))
+	}
+}
+
+func (c *traceCollector) observe(key traceEdgeKey, latency time.Duration) {
+	c.mu.Lock()
+	histogram, exists := c.histograms[key]
+	if !exists {
+		histogram = metrics.NewHistogram(metrics.LatencyBuckets)
+		c.histograms[key] = histogram
+	}
+	c.mu.Unlock()
+
+	histogram.ObserveDuration(latency)
+}
+
+// forget drops the latencies of every edge to the mailbox, once it has been removed.
+func (c *traceCollector) forget(mailboxUUID uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.retain {
+		return
+	}
+	for key := range c.histograms {
+		if key.toMailbox == mailboxUUID {
+			delete(c.histograms, key)
+		}
+	}
+}
+
+// retainAll stops forgetting the latencies of removed mailboxes.
+func (c *traceCollector) retainAll() {
+	c.mu.Lock()
+	c.retain = true
+	c.mu.Unlock()
+}
+
+// edges returns a summary of every edge, sorted by kind, source and destination.
+func (c *traceCollector) edges() []TraceEdge {
+	c.mu.Lock()
+	edges := make([]TraceEdge, 0, len(c.histograms))
+	for key, histogram := range c.histograms {
+		edges = append(edges, TraceEdge{
+			Kind:       key.kind,
+			FromWorker: key.fromWorker,
+			ToMailbox:  key.toMailbox,
+			snapshot:   histogram.Snapshot(),
+		})
+	}
+	c.mu.Unlock()
+
+	for i := range edges {
+		edges[i].Count = edges[i].snapshot.Count
+		edges[i].MeanSeconds = edges[i].snapshot.Mean()
+		edges[i].P50Seconds = edges[i].snapshot.Quantile(0.5)
+		edges[i].P90Seconds = edges[i].snapshot.Quantile(0.9)
+		edges[i].P99Seconds = edges[i].snapshot.Quantile(0.99)
+	}
+
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Kind != edges[j].Kind {
+			return edges[i].Kind < edges[j].Kind
+		}
+		if edges[i].FromWorker != edges[j].FromWorker {
+			return edges[i].FromWorker.String() < edges[j].FromWorker.String()
+		}
+		return edges[i].ToMailbox.String() < edges[j].ToMailbox.String()
+	})
+	return edges
+}
+
+// TraceEdges returns the latency summary of every traced edge, labelled with the types of the sending worker and
+// the destination mailbox's owner if they are still on the node.
+func (n *Node) TraceEdges() []TraceEdge {
+	edges := n.traces.edges()
+
+	workerTypes := make(map[uuid.UUID]string)
+	mailboxOwners := make(map[uuid.UUID]string)
+	n.mu.Lock()
+	for workerUUID, wc := range n.workers {
+		workerTypes[workerUUID] = wc.workerType
+		if wc.services == nil {
+			continue
+		}
+		for _, mailboxUUID := range wc.services.ownedMailboxes() {
+			mailboxOwners[mailboxUUID] = wc.workerType
+		}
+	}
+	n.mu.Unlock()
+
+	for i := range edges {
+		edges[i].FromType = workerTypes[edges[i].FromWorker]
+		edges[i].ToType = mailboxOwners[edges[i].ToMailbox]
+	}
+	return edges
+}
+
+// collectTraces writes the per-edge latency histograms.
+func (n *Node) collectTraces(w *metrics.Writer) {
+	edges := n.TraceEdges()
+
+	families := []struct {
+		name string
+		help string
+		kind TraceEdgeKind
+	}{
+		{"tessera_trace_hop_seconds", "Latency from a worker's send to the message leaving the destination mailbox's queue.", TraceHop},
+		{"tessera_trace_origin_age_seconds", "Age of messages leaving a mailbox's queue, by the worker that started their trace.", TraceOrigin},
+	}
+
+	for _, family := range families {
+		w.Header(family.name, family.help, metrics.HistogramType)
+		for _, edge := range edges {
+			if edge.Kind != family.kind {
+				continue
+			}
+			w.Histogram(family.name, edge.snapshot,
+				"from_worker", edge.FromWorker.String(),
+				"from_type", edge.FromType,
+				"to_mailbox", edge.ToMailbox.String(),
+				"to_type", edge.ToType,
+			)
+		}
+	}
+}
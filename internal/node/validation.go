@@ -0,0 +1,273 @@
+package node
+
+import (
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/google/uuid"
+	"strings"
+)
+
+// ValidationIssue describes a problem with an instruction, found while validating tasks.
+type ValidationIssue struct {
+	Task        int    `json:"task"`
+	Instruction int    `json:"instruction"`
+	Type        string `json:"type,omitempty"`
+	Message     string `json:"message"`
+}
+
+// ValidationError collects every issue found while validating tasks, and the warnings about instructions that may
+// still apply.
+type ValidationError struct {
+	Issues   []ValidationIssue
+	Warnings []ValidationIssue
+}
+
+// HasIssues reports whether validation found issues, as opposed to warnings only.
+func (e *ValidationError) HasIssues() bool {
+	return e != nil && len(e.Issues) > 0
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		messages = append(messages, fmt.Sprintf("task %d instruction %d (%s): %s", issue.Task, issue.Instruction, issue.Type, issue.Message))
+	}
+	return fmt.Sprintf("%d validation issue(s): %s", len(e.Issues), strings.Join(messages, "; "))
+}
+
+// plannedWorker is the simulated state of a worker while validating a plan.
+type plannedWorker struct {
+	workerType string
+	active     bool
+	inputs     []uuid.UUID
+}
+
+// plannedSend records a destination mailbox that a started worker sends to.
+type plannedSend struct {
+	task            int
+	instruction     int
+	instructionType string
+	workerUUID      uuid.UUID
+	mailboxUUID     uuid.UUID
+}
+
+// ValidateTasks checks tasks, in order, against the current state of the node without applying anything.
+// It checks that every worker type is registered, that every worker config matches the config type registered
+// for its worker type and parses with its worker's parser, and that every destination mailbox is created by some
+// worker in the plan or already exists on the node. On a bridged node, a destination that is not local must
+// resolve to another node through the bridge's routes; one that does not, possibly because discovery has not seen
+// its node yet, is reported as a warning. A nil return means no issues or warnings were found; one with warnings
+// only does not stop the tasks from being applied.
+func (n *Node) ValidateTasks(tasks ...Task) *ValidationError {
+	n.mu.Lock()
+	workers := make(map[uuid.UUID]*plannedWorker, len(n.workers))
+	for workerUUID, wc := range n.workers {
+		planned := &plannedWorker{workerType: wc.workerType, active: wc.status.isActive}
+		if wc.services != nil {
+			planned.inputs = wc.services.ownedMailboxes()
+		}
+		workers[workerUUID] = planned
+	}
+	bridge := n.bridge
+	n.mu.Unlock()
+	resolver, resolves := bridge.(MailboxResolver)
+
+	// mailboxes tracks the mailboxes that exist as the plan is simulated, and created every mailbox that exists at
+	// any point of it.
+	mailboxes := make(map[uuid.UUID]bool)
+	created := make(map[uuid.UUID]bool)
+	for _, mailboxUUID := range n.dispatcher.ListMailboxes() {
+		mailboxes[mailboxUUID] = true
+		created[mailboxUUID] = true
+	}
+
+	var issues, warnings []ValidationIssue
+	var sends []plannedSend
+	issue := func(taskIndex int, instructionIndex int, instructionType string, format string, args ...any) ValidationIssue {
+		return ValidationIssue{
+			Task:        taskIndex,
+			Instruction: instructionIndex,
+			Type:        instructionType,
+			Message:     fmt.Sprintf(format, args...),
+		}
+	}
+	report := func(taskIndex int, instructionIndex int, instructionType string, format string, args ...any) {
+		issues = append(issues, issue(taskIndex, instructionIndex, instructionType, format, args...))
+	}
+
+	for taskIndex, task := range tasks {
+		for instructionIndex, instruction := range task.Instructions {
+			fail := func(format string, args ...any) {
+				report(taskIndex, instructionIndex, instruction.Type, format, args...)
+			}
+
+			switch args := instruction.Args.(type) {
+			case CreateWorkerInstructionArgs:
+				if _, exists := workers[args.WorkerUUID]; exists {
+					fail("worker %s already exists", args.WorkerUUID)
+					continue
+				}
+				if _, err := n.workerFactory.InstantiateWorker(args.WorkerType); err != nil {
+					fail("%v", err)
+					continue
+				}
+				workers[args.WorkerUUID] = &plannedWorker{workerType: args.WorkerType}
+
+			case StartWorkerInstructionArgs:
+				planned, exists := workers[args.WorkerUUID]
+				if !exists {
+					fail("worker %s is not created", args.WorkerUUID)
+					continue
+				}
+				if planned.active {
+					fail("worker %s is already active", args.WorkerUUID)
+					continue
+				}
+				planned.active = true
+
+				info, inspected, err := n.inspectConfig(planned.workerType, args.WorkerRawConfig)
+				if err != nil {
+					fail("invalid config for %s worker %s: %v", planned.workerType, args.WorkerUUID, err)
+					continue
+				}
+				if !inspected {
+					// Workers without an inspector can only be checked further by running them.
+					continue
+				}
+
+				for _, mailboxUUID := range info.InputMailboxes {
+					if mailboxes[mailboxUUID] {
+						fail("mailbox %s created by worker %s already exists", mailboxUUID, args.WorkerUUID)
+						continue
+					}
+					mailboxes[mailboxUUID] = true
+					created[mailboxUUID] = true
+				}
+				planned.inputs = info.InputMailboxes
+
+				for _, mailboxUUID := range info.OutputMailboxes {
+					sends = append(sends, plannedSend{
+						task:            taskIndex,
+						instruction:     instructionIndex,
+						instructionType: instruction.Type,
+						workerUUID:      args.WorkerUUID,
+						mailboxUUID:     mailboxUUID,
+					})
+				}
+
+			case StopWorkerInstructionArgs:
+				planned, exists := workers[args.WorkerUUID]
+				if !exists || !planned.active {
+					fail("worker %s is not registered or not active", args.WorkerUUID)
+					continue
+				}
+				planned.active = false
+				for _, mailboxUUID := range planned.inputs {
+					delete(mailboxes, mailboxUUID)
+				}
+
+			case ReconfigureWorkerInstructionArgs:
+				planned, exists := workers[args.WorkerUUID]
+				if !exists || !planned.active {
+					fail("worker %s is not registered or not active", args.WorkerUUID)
+					continue
+				}
+
+				info, inspected, err := n.inspectConfig(planned.workerType, args.WorkerRawConfig)
+				if err != nil {
+					fail("invalid config for %s worker %s: %v", planned.workerType, args.WorkerUUID, err)
+					continue
+				}
+				if !inspected {
+					continue
+				}
+
+				// A change of input mailboxes restarts the worker, which replaces its mailboxes.
+				for _, mailboxUUID := range planned.inputs {
+					delete(mailboxes, mailboxUUID)
+				}
+				for _, mailboxUUID := range info.InputMailboxes {
+					if mailboxes[mailboxUUID] {
+						fail("mailbox %s created by worker %s already exists", mailboxUUID, args.WorkerUUID)
+						continue
+					}
+					mailboxes[mailboxUUID] = true
+					created[mailboxUUID] = true
+				}
+				planned.inputs = info.InputMailboxes
+
+				for _, mailboxUUID := range info.OutputMailboxes {
+					sends = append(sends, plannedSend{
+						task:            taskIndex,
+						instruction:     instructionIndex,
+						instructionType: instruction.Type,
+						workerUUID:      args.WorkerUUID,
+						mailboxUUID:     mailboxUUID,
+					})
+				}
+
+			case RemoveWorkerInstructionArgs:
+				planned, exists := workers[args.WorkerUUID]
+				if !exists {
+					fail("worker %s is not registered", args.WorkerUUID)
+					continue
+				}
+				if planned.active {
+					fail("worker %s is active", args.WorkerUUID)
+					continue
+				}
+				delete(workers, args.WorkerUUID)
+
+			default:
+				fail("unknown instruction: %s", instruction.Type)
+			}
+		}
+	}
+
+	// Destinations are checked against the whole plan, since senders are commonly started before receivers.
+	// Mailboxes that are not local may live on another node when the node is bridged.
+	for _, send := range sends {
+		switch {
+		case created[send.mailboxUUID]:
+		case bridge == nil:
+			report(send.task, send.instruction, send.instructionType, "worker %s sends to mailbox %s, which no worker creates", send.workerUUID, send.mailboxUUID)
+		case resolves:
+			if _, ok := resolver.ResolveMailbox(send.mailboxUUID); !ok {
+				warnings = append(warnings, issue(send.task, send.instruction, send.instructionType, "worker %s sends to mailbox %s, which no worker creates and no known node owns", send.workerUUID, send.mailboxUUID))
+			}
+		default:
+			warnings = append(warnings, issue(send.task, send.instruction, send.instructionType, "worker %s sends to mailbox %s, which no worker creates and the bridge cannot check", send.workerUUID, send.mailboxUUID))
+		}
+	}
+
+	if len(issues) == 0 && len(warnings) == 0 {
+		return nil
+	}
+	return &ValidationError{Issues: issues, Warnings: warnings}
+}
+
+// inspectConfig checks a raw config for a worker type, first against the config type registered with the worker
+// factory, then with the worker's own inspector. It reports whether the worker has an inspector, without which the
+// returned info is empty.
+func (n *Node) inspectConfig(workerType string, rawConfig any) (worker.ConfigInfo, bool, error) {
+	if registry, ok := n.workerFactory.(ConfigRegistry); ok {
+		if err := registry.CheckConfig(workerType, rawConfig); err != nil {
+			return worker.ConfigInfo{}, false, err
+		}
+	}
+
+	instance, err := n.workerFactory.InstantiateWorker(workerType)
+	if err != nil {
+		return worker.ConfigInfo{}, false, err
+	}
+	inspector, ok := instance.(worker.ConfigInspector)
+	if !ok {
+		return worker.ConfigInfo{}, false, nil
+	}
+
+	info, err := inspector.InspectConfig(rawConfig)
+	if err != nil {
+		return worker.ConfigInfo{}, true, err
+	}
+	return info, true, nil
+}
@@ -1,73 +1,290 @@
 package node
 
 import (
+	"errors"
 	"fmt"
 	"github.com/PhillipMichelsen/Tessera/internal/worker"
 	"github.com/google/uuid"
+	"sync"
+	"time"
 )
 
 type WorkerServices struct {
-	node *Node
+	node       *Node
+	workerUUID uuid.UUID
+	workerType string
+	sequences  *sequencer
 
-	mailboxUUIDs []uuid.UUID
-	messagesSent int
+	mu                 sync.Mutex
+	mailboxUUIDs       []uuid.UUID
+	eventSubscriptions map[uuid.UUID]uuid.UUID
+	messagesSent       int
+
+	readyOnce sync.Once
+	ready     chan struct{} // Closed when the worker reports it is ready.
+	exited    chan struct{} // Closed once the worker has exited and its services are cleaned up.
+}
+
+// sequencer numbers the messages a worker sends to each destination mailbox. It belongs to the worker's container,
+// so numbering continues across restarts.
+type sequencer struct {
+	mu   sync.Mutex
+	last map[uuid.UUID]uint64
+}
+
+func newSequencer() *sequencer {
+	return &sequencer{last: make(map[uuid.UUID]uint64)}
 }
 
-func NewWorkerServices(node *Node) WorkerServices {
-	return WorkerServices{
-		node:         node,
-		mailboxUUIDs: make([]uuid.UUID, 0),
-		messagesSent: 0,
+// next returns the next sequence number for the destination mailbox.
+func (s *sequencer) next(destinationMailboxUUID uuid.UUID) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.last[destinationMailboxUUID]++
+	return s.last[destinationMailboxUUID]
+}
+
+func NewWorkerServices(node *Node, workerUUID uuid.UUID, workerType string) *WorkerServices {
+	return &WorkerServices{
+		node:               node,
+		workerUUID:         workerUUID,
+		workerType:         workerType,
+		sequences:          newSequencer(),
+		mailboxUUIDs:       make([]uuid.UUID, 0),
+		eventSubscriptions: make(map[uuid.UUID]uuid.UUID),
+		messagesSent:       0,
+		ready:              make(chan struct{}),
+		exited:             make(chan struct{}),
 	}
 }
 
-func (ws WorkerServices) SendMessage(destinationMailboxUUID uuid.UUID, message worker.Message, block bool) error {
-	// Intra-node message case, can be directly pushed to mailbox.
-	if ws.node.dispatcher.CheckMailboxExists(destinationMailboxUUID) {
-		var err error
-		if block {
-			err = ws.node.dispatcher.PushMessageBlocking(destinationMailboxUUID, message)
-		} else {
-			err = ws.node.dispatcher.PushMessage(destinationMailboxUUID, message)
-		}
+func (ws *WorkerServices) SendMessage(destinationMailboxUUID uuid.UUID, message worker.Message, block bool) error {
+	return ws.send(destinationMailboxUUID, message, block, true)
+}
 
-		if err != nil {
-			return fmt.Errorf("failed to send message to destination mailbox %s: %w", destinationMailboxUUID, err)
+// send stamps and sends the message. Sequenced sends are numbered per destination and dead-lettered if they fail.
+func (ws *WorkerServices) send(destinationMailboxUUID uuid.UUID, message worker.Message, block bool, sequenced bool) error {
+	start := time.Now()
+	ws.stamp(destinationMailboxUUID, &message, start, sequenced)
+	err := ws.node.sendMessage(destinationMailboxUUID, message, block)
+	ws.node.stats.observeSend(ws.workerType, time.Since(start), err)
+	if err != nil {
+		if sequenced {
+			ws.node.deadLetter(ws.workerUUID, ws.workerType, destinationMailboxUUID, message, err)
 		}
-		ws.messagesSent++
-		return nil
+		return err
 	}
 
-	// Inter-node message case, needs to be routed through the bridge.
-	// First, resolve the destination node. Done with the cluster discovery service.
-	// Then, bridge the message to the destination node with the bridge.
-	// TODO: Implement the above. Start in a new branch, do alongside the orchestrator and related components.
+	ws.mu.Lock()
+	ws.messagesSent++
+	ws.mu.Unlock()
+	return nil
+}
 
-	return fmt.Errorf("unimplemented non intra-node message routing to destination mailbox %s", destinationMailboxUUID)
+// SendControl sends the message in the control lane of the destination mailbox.
+func (ws *WorkerServices) SendControl(destinationMailboxUUID uuid.UUID, message worker.Message, block bool) error {
+	message.Priority = worker.PriorityControl
+	return ws.SendMessage(destinationMailboxUUID, message, block)
 }
 
-func (ws WorkerServices) CreateMailbox(mailboxUUID uuid.UUID, bufferSize int) (<-chan any, error) {
-	mailbox, err := ws.node.dispatcher.CreateMailbox(mailboxUUID, bufferSize)
+// stamp fills in the envelope of a message sent by the worker and opens a trace span for the send. CreatedAt and
+// Origin and Headers are kept if already set, so a forwarded message keeps its original creation time and origin.
+// Unsequenced messages carry sequence 0.
+func (ws *WorkerServices) stamp(destinationMailboxUUID uuid.UUID, message *worker.Message, now time.Time, sequenced bool) {
+	message.Source = ws.workerUUID
+	message.Sequence = 0
+	if sequenced {
+		message.Sequence = ws.sequences.next(destinationMailboxUUID)
+	}
+	if message.Origin == uuid.Nil {
+		message.Origin = ws.workerUUID
+	}
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = now
+	}
+	message.StartSpan(ws.workerUUID, destinationMailboxUUID, now)
+
+	ws.mu.Lock()
+	message.SourceMailbox = uuid.Nil
+	if len(ws.mailboxUUIDs) > 0 {
+		message.SourceMailbox = ws.mailboxUUIDs[0]
+	}
+	ws.mu.Unlock()
+}
+
+func (ws *WorkerServices) CreateMailbox(mailboxUUID uuid.UUID, bufferSize int, opts ...worker.MailboxOption) (<-chan any, error) {
+	mailbox, err := ws.node.dispatcher.CreateMailbox(mailboxUUID, bufferSize, opts...)
 	if err != nil {
 		return nil, err
 	}
-	ws.mailboxUUIDs = append(ws.mailboxUUIDs, mailboxUUID)
+	ws.ownMailbox(mailboxUUID)
 	return mailbox, nil
 }
 
-func (ws WorkerServices) RemoveMailbox(mailboxUUID uuid.UUID) {
+// CreateInbox creates a mailbox owned by the worker, returning an inbox to receive from it.
+func (ws *WorkerServices) CreateInbox(mailboxUUID uuid.UUID, bufferSize int, opts ...worker.MailboxOption) (worker.Inbox, error) {
+	inbox, err := ws.node.dispatcher.CreateInbox(mailboxUUID, bufferSize, opts...)
+	if err != nil {
+		return nil, err
+	}
+	ws.ownMailbox(mailboxUUID)
+	return inbox, nil
+}
+
+// ownMailbox records a mailbox created by the worker, so it is removed when the worker exits, and announces it.
+func (ws *WorkerServices) ownMailbox(mailboxUUID uuid.UUID) {
+	ws.mu.Lock()
+	ws.mailboxUUIDs = append(ws.mailboxUUIDs, mailboxUUID)
+	ws.mu.Unlock()
+
+	ws.publishMailboxEvent(worker.MailboxCreatedEvent, mailboxUUID)
+}
+
+func (ws *WorkerServices) RemoveMailbox(mailboxUUID uuid.UUID) {
 	ws.node.dispatcher.RemoveMailbox(mailboxUUID)
 
+	ws.mu.Lock()
+	owned := false
 	for i, currentUUID := range ws.mailboxUUIDs {
 		if currentUUID == mailboxUUID {
 			ws.mailboxUUIDs = append(ws.mailboxUUIDs[:i], ws.mailboxUUIDs[i+1:]...)
+			owned = true
 			break
 		}
 	}
+	ws.mu.Unlock()
+
+	// Workers commonly remove their mailboxes more than once on exit; only the first removal is announced.
+	if owned {
+		ws.node.topics.unsubscribeMailbox(mailboxUUID)
+		ws.publishMailboxEvent(worker.MailboxRemovedEvent, mailboxUUID)
+	}
+}
+
+// SubscribeEvents delivers every node event to the given mailbox as a message tagged worker.EventMessageTag.
+// Events are pushed without blocking, so they are dropped while the mailbox is full.
+func (ws *WorkerServices) SubscribeEvents(mailboxUUID uuid.UUID) error {
+	if !ws.node.dispatcher.CheckMailboxExists(mailboxUUID) {
+		return fmt.Errorf("mailbox %s does not exist", mailboxUUID)
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if _, exists := ws.eventSubscriptions[mailboxUUID]; exists {
+		return fmt.Errorf("mailbox %s is already subscribed to events", mailboxUUID)
+	}
+
+	ws.eventSubscriptions[mailboxUUID] = ws.node.events.Subscribe(func(event worker.Event) {
+		_ = ws.node.dispatcher.PushMessage(mailboxUUID, worker.Message{
+			Tag:       worker.EventMessageTag,
+			Payload:   event,
+			CreatedAt: event.Time,
+		})
+	})
+
+	return nil
+}
+
+// UnsubscribeEvents stops delivering node events to the given mailbox.
+func (ws *WorkerServices) UnsubscribeEvents(mailboxUUID uuid.UUID) {
+	ws.mu.Lock()
+	subscriptionID, exists := ws.eventSubscriptions[mailboxUUID]
+	delete(ws.eventSubscriptions, mailboxUUID)
+	ws.mu.Unlock()
+
+	if exists {
+		ws.node.events.Unsubscribe(subscriptionID)
+	}
+}
+
+// Publish sends the message to every mailbox subscribed to the topic, as SendMessage would to each in turn.
+// Delivery continues past failed subscribers, whose errors are returned together.
+func (ws *WorkerServices) Publish(topic string, message worker.Message, block bool) error {
+	subscribers, err := ws.node.topics.publish(topic, message.Payload)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, mailboxUUID := range subscribers {
+		if err := ws.SendMessage(mailboxUUID, message, block); err != nil {
+			errs = append(errs, fmt.Errorf("failed to publish to topic %q: %w", topic, err))
+		}
+	}
+	return errors.Join(errs...)
 }
 
-func (ws WorkerServices) cleanupMailboxes() {
-	for _, mailboxUUID := range ws.mailboxUUIDs {
+// Subscribe delivers the messages published to the topic to the given mailbox.
+func (ws *WorkerServices) Subscribe(topic string, mailboxUUID uuid.UUID) error {
+	if !ws.node.dispatcher.CheckMailboxExists(mailboxUUID) {
+		return fmt.Errorf("mailbox %s does not exist", mailboxUUID)
+	}
+	return ws.node.topics.subscribe(topic, mailboxUUID, ws.workerUUID)
+}
+
+// Unsubscribe stops delivering the topic's messages to the given mailbox.
+func (ws *WorkerServices) Unsubscribe(topic string, mailboxUUID uuid.UUID) {
+	ws.node.topics.unsubscribe(topic, mailboxUUID)
+}
+
+// DeadLetter queues a message the worker could not handle on the node's dead-letter queue.
+func (ws *WorkerServices) DeadLetter(mailboxUUID uuid.UUID, message worker.Message, reason error) {
+	ws.node.deadLetter(ws.workerUUID, ws.workerType, mailboxUUID, message, reason)
+}
+
+// Ready marks the worker as ready on the node, releasing a start_worker instruction waiting for it.
+func (ws *WorkerServices) Ready() {
+	ws.readyOnce.Do(func() {
+		close(ws.ready)
+		ws.node.markReady(ws)
+	})
+}
+
+// publishMailboxEvent announces a change to one of the worker's mailboxes on the node's event bus.
+func (ws *WorkerServices) publishMailboxEvent(eventType worker.EventType, mailboxUUID uuid.UUID) {
+	ws.node.events.Publish(worker.Event{
+		Type:        eventType,
+		Time:        time.Now(),
+		WorkerUUID:  ws.workerUUID,
+		WorkerType:  ws.workerType,
+		MailboxUUID: mailboxUUID,
+	})
+}
+
+// ownedMailboxes returns a copy of the mailbox UUIDs currently owned by the worker.
+func (ws *WorkerServices) ownedMailboxes() []uuid.UUID {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	mailboxUUIDs := make([]uuid.UUID, len(ws.mailboxUUIDs))
+	copy(mailboxUUIDs, ws.mailboxUUIDs)
+	return mailboxUUIDs
+}
+
+// cleanup releases everything the worker acquired through its services. Called once the worker has exited.
+func (ws *WorkerServices) cleanup() {
+	ws.cleanupSubscriptions()
+	ws.node.topics.unsubscribeWorker(ws.workerUUID)
+	ws.cleanupMailboxes()
+	close(ws.exited)
+}
+
+func (ws *WorkerServices) cleanupMailboxes() {
+	for _, mailboxUUID := range ws.ownedMailboxes() {
 		ws.RemoveMailbox(mailboxUUID)
 	}
 }
+
+func (ws *WorkerServices) cleanupSubscriptions() {
+	ws.mu.Lock()
+	mailboxUUIDs := make([]uuid.UUID, 0, len(ws.eventSubscriptions))
+	for mailboxUUID := range ws.eventSubscriptions {
+		mailboxUUIDs = append(mailboxUUIDs, mailboxUUID)
+	}
+	ws.mu.Unlock()
+
+	for _, mailboxUUID := range mailboxUUIDs {
+		ws.UnsubscribeEvents(mailboxUUID)
+	}
+}
@@ -0,0 +1,119 @@
+package orchestrator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/controlplane"
+	"github.com/PhillipMichelsen/Tessera/internal/node"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"net/http"
+	"strings"
+)
+
+// nodeClient talks to a node's control plane.
+type nodeClient struct {
+	id         string
+	baseURL    string
+	httpClient *http.Client
+}
+
+func newNodeClient(id string, baseURL string, httpClient *http.Client) *nodeClient {
+	return &nodeClient{
+		id:         id,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// submitTask sends the task to the node and returns an error if any instruction failed.
+func (c *nodeClient) submitTask(ctx context.Context, task node.Task) error {
+	taskYaml, err := node.MarshalTask(task)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/tasks", bytes.NewReader(taskYaml))
+	if err != nil {
+		return fmt.Errorf("failed to build task request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/yaml")
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to submit task to node %s: %w", c.id, err)
+	}
+	defer response.Body.Close()
+
+	var taskResponse controlplane.TaskResponse
+	if err := json.NewDecoder(response.Body).Decode(&taskResponse); err != nil {
+		return fmt.Errorf("failed to decode task response from node %s: %w", c.id, err)
+	}
+	if taskResponse.Error != "" {
+		return fmt.Errorf("node %s rejected task: %s", c.id, taskResponse.Error)
+	}
+
+	return nil
+}
+
+// listWorkers returns the status of every worker on the node.
+func (c *nodeClient) listWorkers(ctx context.Context) ([]node.WorkerInfo, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/workers", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build workers request: %w", err)
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workers on node %s: %w", c.id, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list workers on node %s: %s", c.id, response.Status)
+	}
+
+	var workers []node.WorkerInfo
+	if err := json.NewDecoder(response.Body).Decode(&workers); err != nil {
+		return nil, fmt.Errorf("failed to decode workers from node %s: %w", c.id, err)
+	}
+	return workers, nil
+}
+
+// streamEvents calls handle for every event the node emits until the context is cancelled or the stream breaks.
+func (c *nodeClient) streamEvents(ctx context.Context, handle func(event worker.Event)) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/events", nil)
+	if err != nil {
+		return fmt.Errorf("failed to build events request: %w", err)
+	}
+
+	// The event stream is long-lived, so it must not be subject to the client's request timeout.
+	streamClient := *c.httpClient
+	streamClient.Timeout = 0
+
+	response, err := streamClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to stream events from node %s: %w", c.id, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to stream events from node %s: %s", c.id, response.Status)
+	}
+
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		var event worker.Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("failed to decode event from node %s: %w", c.id, err)
+		}
+		handle(event)
+	}
+
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("event stream from node %s broke: %w", c.id, err)
+	}
+	return nil
+}
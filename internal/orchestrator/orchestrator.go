@@ -0,0 +1,447 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/node"
+	"github.com/PhillipMichelsen/Tessera/internal/pipeline"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/google/uuid"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// eventRetryDelay is how long the orchestrator waits before reconnecting to a node's event stream.
+const eventRetryDelay = 2 * time.Second
+
+// Orchestrator owns pipelines spread over multiple nodes. It places each pipeline on a registered node, issues the
+// tasks that create and start its workers, and reconciles the desired pipelines against the workers actually running.
+type Orchestrator struct {
+	httpClient *http.Client
+
+	mu         sync.Mutex
+	nodes      map[string]*nodeClient
+	pipelines  map[string]pipeline.Compiled
+	placements map[uuid.UUID]string
+
+	reconcileMu       sync.Mutex
+	reconcileRequests chan struct{}
+}
+
+// NewOrchestrator creates an orchestrator that talks to node control planes with the given client.
+func NewOrchestrator(httpClient *http.Client) *Orchestrator {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Orchestrator{
+		httpClient:        httpClient,
+		nodes:             make(map[string]*nodeClient),
+		pipelines:         make(map[string]pipeline.Compiled),
+		placements:        make(map[uuid.UUID]string),
+		reconcileRequests: make(chan struct{}, 1),
+	}
+}
+
+// RegisterNode makes a node available for placement. controlURL is the base URL of its control plane.
+func (o *Orchestrator) RegisterNode(id string, controlURL string) error {
+	if id == "" || controlURL == "" {
+		return fmt.Errorf("node id and control url are required")
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, exists := o.nodes[id]; exists {
+		return fmt.Errorf("node %s already registered", id)
+	}
+	o.nodes[id] = newNodeClient(id, controlURL, o.httpClient)
+	return nil
+}
+
+// UnregisterNode removes a node. Workers placed on it are placed elsewhere on the next reconcile.
+func (o *Orchestrator) UnregisterNode(id string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.nodes, id)
+	for workerUUID, nodeID := range o.placements {
+		if nodeID == id {
+			delete(o.placements, workerUUID)
+		}
+	}
+}
+
+// Apply records the compiled pipeline as desired state, places its workers and reconciles. Workers are started in
+// the compiled order, receivers before senders.
+// Applying a pipeline with an existing name replaces it: workers dropped from it are stopped and removed as Remove
+// does, and workers that already run are left untouched.
+func (o *Orchestrator) Apply(ctx context.Context, spec pipeline.Compiled) error {
+	if spec.Name == "" {
+		return fmt.Errorf("pipeline name is required")
+	}
+	if len(spec.Workers) == 0 {
+		return fmt.Errorf("pipeline %s has no workers", spec.Name)
+	}
+
+	o.mu.Lock()
+	for _, workerSpec := range spec.Workers {
+		if workerSpec.Node == "" {
+			continue
+		}
+		if _, exists := o.nodes[workerSpec.Node]; !exists {
+			o.mu.Unlock()
+			return fmt.Errorf("worker %s is pinned to unregistered node %s", workerSpec.UUID, workerSpec.Node)
+		}
+	}
+	previous, replacing := o.pipelines[spec.Name]
+	o.pipelines[spec.Name] = spec
+	o.mu.Unlock()
+
+	var errs []error
+	if replacing {
+		if err := o.removeWorkers(ctx, droppedWorkers(previous, spec)); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove workers dropped from pipeline %s: %w", spec.Name, err))
+		}
+	}
+	errs = append(errs, o.Reconcile(ctx))
+	return errors.Join(errs...)
+}
+
+// droppedWorkers returns the workers of the previous spec of a pipeline that its new spec no longer has, in their
+// previous order.
+func droppedWorkers(previous, spec pipeline.Compiled) []pipeline.CompiledWorker {
+	kept := make(map[uuid.UUID]bool, len(spec.Workers))
+	for _, workerSpec := range spec.Workers {
+		kept[workerSpec.UUID] = true
+	}
+
+	var dropped []pipeline.CompiledWorker
+	for _, workerSpec := range previous.Workers {
+		if !kept[workerSpec.UUID] {
+			dropped = append(dropped, workerSpec)
+		}
+	}
+	return dropped
+}
+
+// Remove stops and removes every worker of the pipeline, in reverse order, and forgets it.
+func (o *Orchestrator) Remove(ctx context.Context, name string) error {
+	o.mu.Lock()
+	spec, exists := o.pipelines[name]
+	if !exists {
+		o.mu.Unlock()
+		return fmt.Errorf("pipeline %s not found", name)
+	}
+	delete(o.pipelines, name)
+	o.mu.Unlock()
+
+	return o.removeWorkers(ctx, spec.Workers)
+}
+
+// removeWorkers stops and removes the workers, in reverse order, and deletes their placements.
+func (o *Orchestrator) removeWorkers(ctx context.Context, workerSpecs []pipeline.CompiledWorker) error {
+	o.reconcileMu.Lock()
+	defer o.reconcileMu.Unlock()
+
+	var errs []error
+	for i := len(workerSpecs) - 1; i >= 0; i-- {
+		workerSpec := workerSpecs[i]
+
+		o.mu.Lock()
+		client := o.nodes[o.placements[workerSpec.UUID]]
+		delete(o.placements, workerSpec.UUID)
+		o.mu.Unlock()
+		if client == nil {
+			continue
+		}
+
+		workers, err := client.listWorkers(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, info := range workers {
+			if info.UUID != workerSpec.UUID {
+				continue
+			}
+
+			task := node.Task{}
+			if info.Active {
+				task.Instructions = append(task.Instructions, node.Instruction{
+					Type: "stop_worker",
+					Args: node.StopWorkerInstructionArgs{WorkerUUID: workerSpec.UUID},
+				})
+			}
+			task.Instructions = append(task.Instructions, node.Instruction{
+				Type: "remove_worker",
+				Args: node.RemoveWorkerInstructionArgs{WorkerUUID: workerSpec.UUID},
+			})
+
+			if err := client.submitTask(ctx, task); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Reconcile brings every node in line with the desired pipelines: missing workers are created and started, and
+// workers the node gave up restarting after an abnormal exit are started again. Workers the node is still
+// restarting are left to the node, and workers stopped by hand, that exited normally or that are never to be
+// restarted stay stopped.
+func (o *Orchestrator) Reconcile(ctx context.Context) error {
+	o.reconcileMu.Lock()
+	defer o.reconcileMu.Unlock()
+
+	o.mu.Lock()
+	clients := make(map[string]*nodeClient, len(o.nodes))
+	for id, client := range o.nodes {
+		clients[id] = client
+	}
+	specs := o.sortedPipelines()
+	o.mu.Unlock()
+
+	var errs []error
+
+	actual := make(map[string]map[uuid.UUID]node.WorkerInfo, len(clients))
+	for id, client := range clients {
+		workers, err := client.listWorkers(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		actual[id] = make(map[uuid.UUID]node.WorkerInfo, len(workers))
+		for _, info := range workers {
+			actual[id][info.UUID] = info
+		}
+	}
+
+	for _, spec := range specs {
+		for _, workerSpec := range spec.Workers {
+			nodeID, err := o.place(spec, workerSpec)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			nodeWorkers, reachable := actual[nodeID]
+			if !reachable {
+				continue
+			}
+
+			if err := o.reconcileWorker(ctx, clients[nodeID], workerSpec, nodeWorkers); err != nil {
+				errs = append(errs, fmt.Errorf("pipeline %s: %w", spec.Name, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// reconcileWorker creates and/or starts a single worker on its node as needed.
+func (o *Orchestrator) reconcileWorker(ctx context.Context, client *nodeClient, workerSpec pipeline.CompiledWorker, nodeWorkers map[uuid.UUID]node.WorkerInfo) error {
+	info, exists := nodeWorkers[workerSpec.UUID]
+	if exists && !needsStart(info, workerSpec.RestartPolicy) {
+		return nil
+	}
+
+	if !exists {
+		createTask := node.Task{Instructions: []node.Instruction{workerSpec.CreateInstruction()}}
+		if err := client.submitTask(ctx, createTask); err != nil {
+			return fmt.Errorf("failed to create worker %s: %w", workerSpec.UUID, err)
+		}
+	}
+
+	startTask := node.Task{Instructions: []node.Instruction{workerSpec.StartInstruction()}}
+	if err := client.submitTask(ctx, startTask); err != nil {
+		return fmt.Errorf("failed to start worker %s: %w", workerSpec.UUID, err)
+	}
+
+	fmt.Printf("Orchestrator started worker %s (%s) on node %s\n", workerSpec.UUID, workerSpec.Type, client.id)
+	return nil
+}
+
+// needsStart reports whether an existing worker is to be started: one that was never started, or one that exited
+// abnormally under a policy restarting it and that the node no longer restarts, having run out of retries.
+func needsStart(info node.WorkerInfo, restartPolicy node.RestartPolicy) bool {
+	if info.Active || info.Restarting {
+		return false
+	}
+	if info.LastStart.IsZero() {
+		return true
+	}
+	if info.LastExitCode == worker.NormalExit.String() {
+		return false
+	}
+	return restartPolicy.Policy == node.RestartOnFailure || restartPolicy.Policy == node.RestartAlways
+}
+
+// place returns the node the worker is placed on, choosing one if it has not been placed yet.
+// Workers of a pipeline message each other through local mailboxes, so unpinned workers are kept on the node of the
+// rest of their pipeline; only pinning splits a pipeline across nodes, which then have to be bridged. A pipeline with
+// nothing placed yet goes to the node of its first pinned worker, or else the registered node with the fewest placed
+// workers.
+func (o *Orchestrator) place(spec pipeline.Compiled, workerSpec pipeline.CompiledWorker) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if nodeID, placed := o.placements[workerSpec.UUID]; placed {
+		return nodeID, nil
+	}
+
+	if workerSpec.Node != "" {
+		if _, exists := o.nodes[workerSpec.Node]; !exists {
+			return "", fmt.Errorf("worker %s is pinned to unregistered node %s", workerSpec.UUID, workerSpec.Node)
+		}
+		o.placements[workerSpec.UUID] = workerSpec.Node
+		return workerSpec.Node, nil
+	}
+
+	if nodeID, ok := o.pipelineNode(spec); ok {
+		o.placements[workerSpec.UUID] = nodeID
+		return nodeID, nil
+	}
+
+	if len(o.nodes) == 0 {
+		return "", fmt.Errorf("no nodes registered to place worker %s on", workerSpec.UUID)
+	}
+
+	load := make(map[string]int, len(o.nodes))
+	nodeIDs := make([]string, 0, len(o.nodes))
+	for id := range o.nodes {
+		nodeIDs = append(nodeIDs, id)
+	}
+	for _, nodeID := range o.placements {
+		load[nodeID]++
+	}
+	sort.Strings(nodeIDs)
+
+	chosen := nodeIDs[0]
+	for _, id := range nodeIDs[1:] {
+		if load[id] < load[chosen] {
+			chosen = id
+		}
+	}
+
+	o.placements[workerSpec.UUID] = chosen
+	return chosen, nil
+}
+
+// pipelineNode returns the node the unpinned workers of the pipeline run on: that of an unpinned worker already placed,
+// else that of the first pinned worker. The orchestrator lock must be held.
+func (o *Orchestrator) pipelineNode(spec pipeline.Compiled) (string, bool) {
+	for _, workerSpec := range spec.Workers {
+		if nodeID, placed := o.placements[workerSpec.UUID]; placed && workerSpec.Node == "" {
+			return nodeID, true
+		}
+	}
+	for _, workerSpec := range spec.Workers {
+		if _, exists := o.nodes[workerSpec.Node]; exists {
+			return workerSpec.Node, true
+		}
+	}
+	return "", false
+}
+
+// Placements returns the node each desired worker is placed on.
+func (o *Orchestrator) Placements() map[uuid.UUID]string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	placements := make(map[uuid.UUID]string, len(o.placements))
+	for workerUUID, nodeID := range o.placements {
+		placements[workerUUID] = nodeID
+	}
+	return placements
+}
+
+// Run reconciles every interval and whenever a node reports that a desired worker failed for good,
+// until the context is cancelled. Nodes must be registered before Run is called.
+func (o *Orchestrator) Run(ctx context.Context, interval time.Duration) {
+	o.mu.Lock()
+	clients := make([]*nodeClient, 0, len(o.nodes))
+	for _, client := range o.nodes {
+		clients = append(clients, client)
+	}
+	o.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o.watchNode(ctx, client)
+		}()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+		case <-o.reconcileRequests:
+		}
+
+		if err := o.Reconcile(ctx); err != nil && ctx.Err() == nil {
+			fmt.Printf("Orchestrator reconcile failed: %v\n", err)
+		}
+	}
+}
+
+// watchNode follows a node's event stream, reconnecting until the context is cancelled.
+func (o *Orchestrator) watchNode(ctx context.Context, client *nodeClient) {
+	for ctx.Err() == nil {
+		err := client.streamEvents(ctx, o.handleEvent)
+		if err != nil && ctx.Err() == nil {
+			fmt.Printf("Orchestrator lost events from node %s: %v\n", client.id, err)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(eventRetryDelay):
+		}
+	}
+}
+
+// handleEvent requests a reconcile when a desired worker exits abnormally and the node will not restart it.
+func (o *Orchestrator) handleEvent(event worker.Event) {
+	if event.Type != worker.WorkerExitedEvent || event.WillRestart || event.ExitCode == worker.NormalExit {
+		return
+	}
+
+	o.mu.Lock()
+	_, desired := o.placements[event.WorkerUUID]
+	o.mu.Unlock()
+	if !desired {
+		return
+	}
+
+	fmt.Printf("Orchestrator saw worker %s exit with %s: %s\n", event.WorkerUUID, event.ExitCode, event.Error)
+	select {
+	case o.reconcileRequests <- struct{}{}:
+	default:
+	}
+}
+
+// sortedPipelines returns the desired pipelines ordered by name. The orchestrator lock must be held.
+func (o *Orchestrator) sortedPipelines() []pipeline.Compiled {
+	specs := make([]pipeline.Compiled, 0, len(o.pipelines))
+	for _, spec := range o.pipelines {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].Name < specs[j].Name
+	})
+	return specs
+}
@@ -0,0 +1,52 @@
+package orchestrator
+
+import (
+	"github.com/PhillipMichelsen/Tessera/internal/node"
+	"github.com/PhillipMichelsen/Tessera/internal/pipeline"
+	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/google/uuid"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNeedsStart(t *testing.T) {
+	started := time.Now()
+	tests := []struct {
+		name   string
+		info   node.WorkerInfo
+		policy node.RestartPolicyKind
+		want   bool
+	}{
+		{"active", node.WorkerInfo{Active: true, LastStart: started}, node.RestartAlways, false},
+		{"restarting", node.WorkerInfo{Restarting: true, LastStart: started}, node.RestartAlways, false},
+		{"never started", node.WorkerInfo{}, node.RestartNever, true},
+		{"stopped by hand", node.WorkerInfo{LastStart: started, LastExitCode: worker.NormalExit.String()}, node.RestartAlways, false},
+		{"failed under default policy", node.WorkerInfo{LastStart: started, LastExitCode: worker.RuntimeErrorExit.String()}, "", false},
+		{"failed under never", node.WorkerInfo{LastStart: started, LastExitCode: worker.PanicExit.String()}, node.RestartNever, false},
+		{"failed under on-failure", node.WorkerInfo{LastStart: started, LastExitCode: worker.PanicExit.String()}, node.RestartOnFailure, true},
+		{"failed under always", node.WorkerInfo{LastStart: started, LastExitCode: worker.PrematureExit.String()}, node.RestartAlways, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsStart(tt.info, node.RestartPolicy{Policy: tt.policy}); got != tt.want {
+				t.Errorf("needsStart returned %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDroppedWorkers(t *testing.T) {
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+	previous := pipeline.Compiled{Workers: []pipeline.CompiledWorker{{UUID: a}, {UUID: b}, {UUID: c}}}
+	spec := pipeline.Compiled{Workers: []pipeline.CompiledWorker{{UUID: b}}}
+
+	var got []uuid.UUID
+	for _, workerSpec := range droppedWorkers(previous, spec) {
+		got = append(got, workerSpec.UUID)
+	}
+	if want := []uuid.UUID{a, c}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dropped %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,424 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/node"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+	"regexp"
+	"slices"
+	"sort"
+)
+
+// namespace seeds the deterministic UUIDs generated for pipelines, so recompiling a spec yields the same UUIDs.
+var namespace = uuid.MustParse("6f1c2f0e-7d3a-4b8e-9a51-3c0d2e4b7a10")
+
+// referencePattern matches ${kind.name} and ${kind.name.field} references in worker configs.
+var referencePattern = regexp.MustCompile(`\$\{([a-z]+)\.([A-Za-z0-9_\-]+)(?:\.([a-z]+))?\}`)
+
+// Compiled is a pipeline with every name resolved.
+type Compiled struct {
+	Name string
+	// Workers are ordered so that every worker starts after the workers it sends to.
+	Workers   []CompiledWorker
+	Mailboxes map[string]uuid.UUID
+}
+
+// CompiledWorker is a worker with its UUID generated and its config references resolved.
+type CompiledWorker struct {
+	Name          string
+	UUID          uuid.UUID
+	Type          string
+	Node          string
+	RawConfig     []byte
+	RestartPolicy node.RestartPolicy
+}
+
+// mailboxDef is a resolved mailbox and the worker that creates it.
+type mailboxDef struct {
+	uuid  uuid.UUID
+	owner string
+}
+
+// compiler holds the state of a single compilation.
+type compiler struct {
+	spec      Spec
+	workers   map[string]WorkerSpec
+	workerIDs map[string]uuid.UUID
+	mailboxes map[string]mailboxDef
+	edges     map[string]EdgeSpec
+	errs      []error
+}
+
+// Compile resolves every name in the spec, reporting all wiring problems together.
+func Compile(spec Spec) (Compiled, error) {
+	c := &compiler{
+		spec:      spec,
+		workers:   make(map[string]WorkerSpec),
+		workerIDs: make(map[string]uuid.UUID),
+		mailboxes: make(map[string]mailboxDef),
+		edges:     make(map[string]EdgeSpec),
+	}
+
+	if spec.Name == "" {
+		c.fail("pipeline name is required")
+	}
+	if len(spec.Workers) == 0 {
+		c.fail("pipeline has no workers")
+	}
+
+	c.declare()
+	compiled := Compiled{
+		Name:      spec.Name,
+		Mailboxes: make(map[string]uuid.UUID, len(c.mailboxes)),
+	}
+	for name, mailbox := range c.mailboxes {
+		compiled.Mailboxes[name] = mailbox.uuid
+	}
+
+	// Track which mailboxes each worker creates and which edges it uses, as seen through its config references.
+	createdMailboxes := make(map[string]bool)
+	usedEdges := make(map[string]map[string]bool)
+
+	resolved := make(map[string]CompiledWorker, len(spec.Workers))
+	for _, workerSpec := range spec.Workers {
+		if _, declared := c.workers[workerSpec.Name]; !declared || resolved[workerSpec.Name].Name != "" {
+			continue
+		}
+
+		usedEdges[workerSpec.Name] = make(map[string]bool)
+		config := cloneNode(&workerSpec.Config)
+		c.resolveNode(config, workerSpec.Name, createdMailboxes, usedEdges[workerSpec.Name])
+
+		var rawConfig []byte
+		if config.Kind != 0 {
+			var err error
+			if rawConfig, err = yaml.Marshal(config); err != nil {
+				c.fail("failed to marshal config of worker %s: %v", workerSpec.Name, err)
+				continue
+			}
+		}
+
+		resolved[workerSpec.Name] = CompiledWorker{
+			Name:          workerSpec.Name,
+			UUID:          c.workerIDs[workerSpec.Name],
+			Type:          workerSpec.Type,
+			Node:          workerSpec.Node,
+			RawConfig:     rawConfig,
+			RestartPolicy: workerSpec.RestartPolicy,
+		}
+	}
+
+	// Every edge must be used by its sender, and must point at a mailbox its owner actually creates.
+	for _, edge := range spec.Edges {
+		if _, ok := c.edges[edge.Name]; !ok {
+			continue
+		}
+		if used := usedEdges[edge.From]; used != nil && !used[edge.Name] {
+			c.fail("edge %s is never referenced by its sender %s", edge.Name, edge.From)
+		}
+		if mailbox, ok := c.mailboxes[edge.To]; ok && !createdMailboxes[edge.To] {
+			c.fail("edge %s sends to mailbox %s, which its owner %s never creates", edge.Name, edge.To, mailbox.owner)
+		}
+	}
+	for _, mailboxSpec := range spec.Mailboxes {
+		if _, ok := c.mailboxes[mailboxSpec.Name]; ok && !createdMailboxes[mailboxSpec.Name] {
+			c.fail("mailbox %s is never referenced by its owner %s", mailboxSpec.Name, mailboxSpec.Owner)
+		}
+	}
+
+	order, err := c.startOrder()
+	if err != nil {
+		c.errs = append(c.errs, err)
+	}
+
+	if len(c.errs) > 0 {
+		return Compiled{}, errors.Join(c.errs...)
+	}
+
+	for _, name := range order {
+		compiled.Workers = append(compiled.Workers, resolved[name])
+	}
+	return compiled, nil
+}
+
+// declare registers every worker, mailbox and edge name, generating UUIDs and checking for duplicates.
+func (c *compiler) declare() {
+	for i, workerSpec := range c.spec.Workers {
+		if workerSpec.Name == "" {
+			c.fail("worker %d has no name", i)
+			continue
+		}
+		if workerSpec.Type == "" {
+			c.fail("worker %s has no type", workerSpec.Name)
+		}
+		if _, exists := c.workers[workerSpec.Name]; exists {
+			c.fail("worker %s is declared more than once", workerSpec.Name)
+			continue
+		}
+
+		workerUUID := workerSpec.UUID
+		if workerUUID == uuid.Nil {
+			workerUUID = c.generateUUID("worker", workerSpec.Name)
+		}
+		c.workers[workerSpec.Name] = workerSpec
+		c.workerIDs[workerSpec.Name] = workerUUID
+		// A worker's implicit mailbox shares its UUID, following the existing task convention.
+		c.mailboxes[workerSpec.Name] = mailboxDef{uuid: workerUUID, owner: workerSpec.Name}
+	}
+
+	for i, mailboxSpec := range c.spec.Mailboxes {
+		if mailboxSpec.Name == "" {
+			c.fail("mailbox %d has no name", i)
+			continue
+		}
+		if _, exists := c.mailboxes[mailboxSpec.Name]; exists {
+			c.fail("mailbox %s clashes with another mailbox or worker name", mailboxSpec.Name)
+			continue
+		}
+		if _, exists := c.workers[mailboxSpec.Owner]; !exists {
+			c.fail("mailbox %s has unknown owner %q", mailboxSpec.Name, mailboxSpec.Owner)
+			continue
+		}
+
+		mailboxUUID := mailboxSpec.UUID
+		if mailboxUUID == uuid.Nil {
+			mailboxUUID = c.generateUUID("mailbox", mailboxSpec.Name)
+		}
+		c.mailboxes[mailboxSpec.Name] = mailboxDef{uuid: mailboxUUID, owner: mailboxSpec.Owner}
+	}
+
+	for i, edge := range c.spec.Edges {
+		if edge.Name == "" {
+			c.fail("edge %d has no name", i)
+			continue
+		}
+		if _, exists := c.edges[edge.Name]; exists {
+			c.fail("edge %s is declared more than once", edge.Name)
+			continue
+		}
+		if _, exists := c.workers[edge.From]; !exists {
+			c.fail("edge %s is sent from unknown worker %q", edge.Name, edge.From)
+			continue
+		}
+		if _, exists := c.mailboxes[edge.To]; !exists {
+			c.fail("edge %s is sent to unknown mailbox %q", edge.Name, edge.To)
+			continue
+		}
+
+		if edge.Tag == "" {
+			edge.Tag = edge.Name
+		}
+		c.edges[edge.Name] = edge
+	}
+}
+
+// resolveNode replaces references in every scalar of the config, including mapping keys.
+func (c *compiler) resolveNode(n *yaml.Node, workerName string, createdMailboxes map[string]bool, usedEdges map[string]bool) {
+	if n.Kind == yaml.ScalarNode {
+		c.resolveScalar(n, workerName, createdMailboxes, usedEdges)
+		return
+	}
+
+	for _, child := range n.Content {
+		c.resolveNode(child, workerName, createdMailboxes, usedEdges)
+	}
+}
+
+// resolveScalar substitutes the references in a single scalar.
+func (c *compiler) resolveScalar(n *yaml.Node, workerName string, createdMailboxes map[string]bool, usedEdges map[string]bool) {
+	if !referencePattern.MatchString(n.Value) {
+		return
+	}
+
+	n.Value = referencePattern.ReplaceAllStringFunc(n.Value, func(reference string) string {
+		parts := referencePattern.FindStringSubmatch(reference)
+		kind, name, field := parts[1], parts[2], parts[3]
+
+		switch kind {
+		case "worker":
+			workerUUID, ok := c.workerIDs[name]
+			if !ok || field != "" {
+				c.fail("worker %s references unknown worker %s", workerName, reference)
+				return reference
+			}
+			// A worker's own UUID doubles as its implicit mailbox UUID.
+			if name == workerName {
+				createdMailboxes[name] = true
+			}
+			return workerUUID.String()
+
+		case "mailbox":
+			mailbox, ok := c.mailboxes[name]
+			if !ok || field != "" {
+				c.fail("worker %s references unknown mailbox %s", workerName, reference)
+				return reference
+			}
+			if mailbox.owner == workerName {
+				createdMailboxes[name] = true
+			}
+			return mailbox.uuid.String()
+
+		case "edge":
+			edge, ok := c.edges[name]
+			if !ok {
+				c.fail("worker %s references unknown edge %s", workerName, reference)
+				return reference
+			}
+			if edge.From != workerName && c.mailboxes[edge.To].owner != workerName {
+				c.fail("worker %s references edge %s but is neither its sender nor its receiver", workerName, name)
+				return reference
+			}
+			if edge.From == workerName {
+				usedEdges[name] = true
+			}
+
+			switch field {
+			case "mailbox":
+				return c.mailboxes[edge.To].uuid.String()
+			case "tag":
+				return edge.Tag
+			default:
+				c.fail("worker %s references unknown edge field %s, expected mailbox or tag", workerName, reference)
+				return reference
+			}
+
+		default:
+			c.fail("worker %s uses unknown reference kind %s", workerName, reference)
+			return reference
+		}
+	})
+
+	// Substituted values are always strings, even when they now look like another YAML type.
+	n.Tag = "!!str"
+	n.Style = yaml.DoubleQuotedStyle
+}
+
+// startOrder orders workers so that each one starts after every worker it sends to, keeping declaration order
+// between unrelated workers.
+func (c *compiler) startOrder() ([]string, error) {
+	dependencies := make(map[string]map[string]bool)
+	for _, edge := range c.edges {
+		receiver := c.mailboxes[edge.To].owner
+		if receiver == edge.From {
+			continue
+		}
+		if dependencies[edge.From] == nil {
+			dependencies[edge.From] = make(map[string]bool)
+		}
+		dependencies[edge.From][receiver] = true
+	}
+
+	var order []string
+	started := make(map[string]bool)
+	remaining := make([]string, 0, len(c.spec.Workers))
+	for _, workerSpec := range c.spec.Workers {
+		if _, declared := c.workers[workerSpec.Name]; declared && !slices.Contains(remaining, workerSpec.Name) {
+			remaining = append(remaining, workerSpec.Name)
+		}
+	}
+
+	for len(remaining) > 0 {
+		progressed := false
+		next := remaining[:0]
+		for _, name := range remaining {
+			ready := true
+			for receiver := range dependencies[name] {
+				if !started[receiver] {
+					ready = false
+					break
+				}
+			}
+
+			if ready {
+				order = append(order, name)
+				started[name] = true
+				progressed = true
+			} else {
+				next = append(next, name)
+			}
+		}
+		remaining = next
+
+		if !progressed {
+			sort.Strings(remaining)
+			return nil, fmt.Errorf("workers %v send to each other in a cycle, so no start order exists", remaining)
+		}
+	}
+
+	return order, nil
+}
+
+// cloneNode deep copies a YAML node so that resolving references leaves the spec untouched.
+func cloneNode(n *yaml.Node) *yaml.Node {
+	clone := *n
+	clone.Content = make([]*yaml.Node, len(n.Content))
+	for i, child := range n.Content {
+		clone.Content[i] = cloneNode(child)
+	}
+	return &clone
+}
+
+// generateUUID derives a stable UUID for a named element of the pipeline.
+func (c *compiler) generateUUID(kind string, name string) uuid.UUID {
+	return uuid.NewSHA1(namespace, []byte(c.spec.Name+"/"+kind+"/"+name))
+}
+
+// fail records a compilation error.
+func (c *compiler) fail(format string, args ...any) {
+	c.errs = append(c.errs, fmt.Errorf(format, args...))
+}
+
+// CreateInstruction returns the create_worker instruction for the worker.
+func (w CompiledWorker) CreateInstruction() node.Instruction {
+	return node.Instruction{
+		Type: "create_worker",
+		Args: node.CreateWorkerInstructionArgs{
+			WorkerType: w.Type,
+			WorkerUUID: w.UUID,
+		},
+	}
+}
+
+// StartInstruction returns the start_worker instruction for the worker.
+func (w CompiledWorker) StartInstruction() node.Instruction {
+	return node.Instruction{
+		Type: "start_worker",
+		Args: node.StartWorkerInstructionArgs{
+			WorkerUUID:      w.UUID,
+			WorkerRawConfig: w.RawConfig,
+			RestartPolicy:   w.RestartPolicy,
+		},
+	}
+}
+
+// CreateTask returns a task creating every worker of the pipeline.
+func (c Compiled) CreateTask() node.Task {
+	task := node.Task{}
+	for _, compiledWorker := range c.Workers {
+		task.Instructions = append(task.Instructions, compiledWorker.CreateInstruction())
+	}
+	return task
+}
+
+// StartTask returns a task starting every worker, receivers before senders.
+func (c Compiled) StartTask() node.Task {
+	task := node.Task{}
+	for _, compiledWorker := range c.Workers {
+		task.Instructions = append(task.Instructions, compiledWorker.StartInstruction())
+	}
+	return task
+}
+
+// StopTask returns a task stopping every worker, senders before receivers.
+func (c Compiled) StopTask() node.Task {
+	task := node.Task{}
+	for i := len(c.Workers) - 1; i >= 0; i-- {
+		task.Instructions = append(task.Instructions, node.Instruction{
+			Type: "stop_worker",
+			Args: node.StopWorkerInstructionArgs{WorkerUUID: c.Workers[i].UUID},
+		})
+	}
+	return task
+}
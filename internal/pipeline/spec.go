@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/node"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is a pipeline described with names instead of UUIDs.
+//
+// Every worker owns an implicit mailbox with the worker's name, and Mailboxes declares any additional mailboxes
+// a worker owns. Edges name the connections from a worker to a mailbox and the tag messages carry on them.
+// Worker configs refer to these by name with ${worker.NAME}, ${mailbox.NAME}, ${edge.NAME.mailbox} and
+// ${edge.NAME.tag}, which the compiler replaces with generated UUIDs and tags.
+type Spec struct {
+	Name      string        `yaml:"name"`
+	Workers   []WorkerSpec  `yaml:"workers"`
+	Mailboxes []MailboxSpec `yaml:"mailboxes"`
+	Edges     []EdgeSpec    `yaml:"edges"`
+}
+
+// WorkerSpec declares a named worker. UUID is generated from the pipeline and worker names unless given.
+// Node optionally pins the worker to a node when the pipeline is applied by an orchestrator; a pipeline with workers
+// pinned to different nodes only works if those nodes are bridged.
+type WorkerSpec struct {
+	Name          string             `yaml:"name"`
+	Type          string             `yaml:"type"`
+	UUID          uuid.UUID          `yaml:"uuid"`
+	Node          string             `yaml:"node"`
+	Config        yaml.Node          `yaml:"config"`
+	RestartPolicy node.RestartPolicy `yaml:"restart_policy"`
+}
+
+// MailboxSpec declares an additional named mailbox created by its owner worker.
+type MailboxSpec struct {
+	Name  string    `yaml:"name"`
+	Owner string    `yaml:"owner"`
+	UUID  uuid.UUID `yaml:"uuid"`
+}
+
+// EdgeSpec declares that worker From sends to mailbox To. Tag defaults to the edge name.
+type EdgeSpec struct {
+	Name string `yaml:"name"`
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+	Tag  string `yaml:"tag"`
+}
+
+// ParseSpec decodes a pipeline spec from YAML.
+func ParseSpec(yamlBytes []byte) (Spec, error) {
+	var spec Spec
+	if err := yaml.Unmarshal(yamlBytes, &spec); err != nil {
+		return Spec{}, fmt.Errorf("failed to unmarshal pipeline spec: %w", err)
+	}
+	return spec, nil
+}
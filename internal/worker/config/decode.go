@@ -0,0 +1,314 @@
+// Package config decodes and validates worker configs, and describes them as JSON Schema.
+//
+// Config structs declare their rules with struct tags next to their yaml tags:
+//
+//	InputMailboxUUID  uuid.UUID     `yaml:"input_mailbox_uuid" validate:"required"`
+//	RangePercentage   float64       `yaml:"range_percentage" validate:"required,min=0,max=100"`
+//	UnmappedTagPolicy MessagePolicy `yaml:"unmapped_tag_policy" default:"dead-letter"`
+//
+// The validate tag takes a comma separated list of rules:
+//   - required: the field must not be empty, i.e. not zero, a nil UUID, or an empty string, map or slice.
+//   - min=N and max=N: bounds on a number, or on the length of a string, map or slice.
+//
+// The default tag holds a YAML value decoded into the field when the config leaves it empty. The fields of a struct
+// embedded with the yaml inline flag are checked as fields of the enclosing struct.
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Validator is implemented by configs, and structs within them, with checks their tags cannot express. Decode calls
+// Validate once the fields' tags have been checked.
+type Validator interface {
+	Validate() error
+}
+
+// Decode unmarshals a worker's raw YAML config, given as []byte, into a T. It fills in the defaults of fields left
+// empty, then checks the validate tags of every field, descending into nested structs, maps and slices.
+func Decode[T any](rawConfig any) (T, error) {
+	var config T
+	if err := decode(rawConfig, reflect.ValueOf(&config).Elem()); err != nil {
+		var zero T
+		return zero, err
+	}
+	return config, nil
+}
+
+// Type is a config type, for checking and describing the configs of a worker type without knowing it statically.
+type Type struct {
+	goType reflect.Type
+}
+
+// TypeOf returns the config type of T.
+func TypeOf[T any]() Type {
+	return Type{goType: reflect.TypeOf((*T)(nil)).Elem()}
+}
+
+// Check decodes the raw config as Decode would, reporting whether it is valid.
+func (t Type) Check(rawConfig any) error {
+	return decode(rawConfig, reflect.New(t.goType).Elem())
+}
+
+// decode unmarshals the raw config into the value, applies its defaults and validates it.
+func decode(rawConfig any, value reflect.Value) error {
+	configBytes, ok := rawConfig.([]byte)
+	if !ok {
+		return fmt.Errorf("config is not in the expected []byte format")
+	}
+
+	if err := yaml.Unmarshal(configBytes, value.Addr().Interface()); err != nil {
+		return fmt.Errorf("failed to unmarshal configuration: %w", err)
+	}
+	if err := applyDefaults(value, ""); err != nil {
+		return err
+	}
+	return validate(value, "")
+}
+
+// field is an exported struct field with the name it has in YAML.
+type field struct {
+	reflect.StructField
+	name string
+}
+
+// fields returns the exported fields of a struct type that are decoded from YAML. The fields of a struct inlined
+// with the yaml inline flag are returned in its place, as they are decoded.
+func fields(structType reflect.Type) []field {
+	var result []field
+	for i := 0; i < structType.NumField(); i++ {
+		structField := structType.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+
+		name, flags, _ := strings.Cut(structField.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if structField.Type.Kind() == reflect.Struct && slices.Contains(strings.Split(flags, ","), "inline") {
+			for _, inlined := range fields(structField.Type) {
+				inlined.Index = append([]int{i}, inlined.Index...)
+				result = append(result, inlined)
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(structField.Name)
+		}
+		result = append(result, field{StructField: structField, name: name})
+	}
+	return result
+}
+
+// join appends a field name or map key to a path.
+func join(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// applyDefaults decodes the default tag of every empty field of the value and the values nested within it.
+func applyDefaults(value reflect.Value, path string) error {
+	switch value.Kind() {
+	case reflect.Struct:
+		for _, f := range fields(value.Type()) {
+			fieldValue := value.FieldByIndex(f.Index)
+			fieldPath := join(path, f.name)
+			if defaultValue, ok := f.Tag.Lookup("default"); ok && isEmpty(fieldValue) {
+				if err := yaml.Unmarshal([]byte(defaultValue), fieldValue.Addr().Interface()); err != nil {
+					return fmt.Errorf("invalid default for %s: %w", fieldPath, err)
+				}
+			}
+			if err := applyDefaults(fieldValue, fieldPath); err != nil {
+				return err
+			}
+		}
+	case reflect.Pointer:
+		if !value.IsNil() {
+			return applyDefaults(value.Elem(), path)
+		}
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			if err := applyDefaults(value.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		// Map values are not addressable, so each is copied, completed and stored back.
+		for _, key := range value.MapKeys() {
+			element := reflect.New(value.Type().Elem()).Elem()
+			element.Set(value.MapIndex(key))
+			if err := applyDefaults(element, join(path, fmt.Sprint(key.Interface()))); err != nil {
+				return err
+			}
+			value.SetMapIndex(key, element)
+		}
+	}
+	return nil
+}
+
+// validate checks the validate tags of the fields of the value and the values nested within it, then calls the
+// Validate method of structs implementing Validator.
+func validate(value reflect.Value, path string) error {
+	switch value.Kind() {
+	case reflect.Struct:
+		for _, f := range fields(value.Type()) {
+			fieldValue := value.FieldByIndex(f.Index)
+			fieldPath := join(path, f.name)
+			if err := checkRules(fieldValue, fieldPath, f.Tag.Get("validate")); err != nil {
+				return err
+			}
+			if err := validate(fieldValue, fieldPath); err != nil {
+				return err
+			}
+		}
+	case reflect.Pointer:
+		if !value.IsNil() {
+			return validate(value.Elem(), path)
+		}
+		return nil
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			if err := validate(value.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		// Keys are visited in order, so the same config always reports the same error.
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			if err := validate(value.MapIndex(key), join(path, fmt.Sprint(key.Interface()))); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return nil
+	}
+
+	validator, ok := value.Interface().(Validator)
+	if !ok && value.CanAddr() {
+		validator, ok = value.Addr().Interface().(Validator)
+	}
+	if !ok {
+		return nil
+	}
+	if err := validator.Validate(); err != nil {
+		if path == "" {
+			return err
+		}
+		return fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return nil
+}
+
+// rule is a single rule of a validate tag, such as required or min=1.
+type rule struct {
+	name  string
+	bound float64
+}
+
+// parseRules parses a validate tag.
+func parseRules(tag string) ([]rule, error) {
+	var rules []rule
+	for _, part := range strings.Split(tag, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, argument, hasArgument := strings.Cut(part, "=")
+		switch name {
+		case "required":
+			if hasArgument {
+				return nil, fmt.Errorf("rule %q takes no argument", name)
+			}
+			rules = append(rules, rule{name: name})
+		case "min", "max":
+			bound, err := strconv.ParseFloat(argument, 64)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q needs a numeric bound: %w", name, err)
+			}
+			rules = append(rules, rule{name: name, bound: bound})
+		default:
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+	}
+	return rules, nil
+}
+
+// checkRules checks a field's value against its validate tag.
+func checkRules(value reflect.Value, path string, tag string) error {
+	rules, err := parseRules(tag)
+	if err != nil {
+		return fmt.Errorf("invalid validate tag on %s: %w", path, err)
+	}
+
+	for _, r := range rules {
+		if r.name == "required" {
+			if isEmpty(value) {
+				return fmt.Errorf("%s is required", path)
+			}
+			continue
+		}
+
+		measure, isLength, ok := measure(value)
+		if !ok {
+			return fmt.Errorf("invalid validate tag on %s: rule %q does not apply to %s", path, r.name, value.Type())
+		}
+		what := "be"
+		if isLength {
+			what = "have a length of"
+		}
+		if r.name == "min" && measure < r.bound {
+			return fmt.Errorf("%s must %s at least %s", path, what, formatBound(r.bound))
+		}
+		if r.name == "max" && measure > r.bound {
+			return fmt.Errorf("%s must %s at most %s", path, what, formatBound(r.bound))
+		}
+	}
+	return nil
+}
+
+// isEmpty reports whether a value is empty for the required rule and defaults.
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String, reflect.Map, reflect.Slice:
+		return value.Len() == 0
+	default:
+		return value.IsZero()
+	}
+}
+
+// measure returns the number that min and max bound: the value of a number, or the length of a string, map or
+// slice.
+func measure(value reflect.Value) (measure float64, isLength bool, ok bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), false, true
+	case reflect.String, reflect.Map, reflect.Slice:
+		return float64(value.Len()), true, true
+	default:
+		return 0, false, false
+	}
+}
+
+func formatBound(bound float64) string {
+	return strconv.FormatFloat(bound, 'g', -1, 64)
+}
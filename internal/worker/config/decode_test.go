@@ -0,0 +1,126 @@
+package config
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testOutput is a nested config with rules and a default of its own.
+type testOutput struct {
+	MailboxUUID uuid.UUID `yaml:"mailbox_uuid" validate:"required"`
+	Tag         string    `yaml:"tag" default:"out"`
+}
+
+// InputConfig is embedded inline, as worker configs embed shared configs. It is exported, as only exported fields
+// are decoded.
+type InputConfig struct {
+	InputMailboxUUID   uuid.UUID `yaml:"input_mailbox_uuid" validate:"required"`
+	InputMailboxBuffer int       `yaml:"input_mailbox_buffer" validate:"min=0" default:"100"`
+}
+
+type testConfig struct {
+	InputConfig `yaml:",inline"`
+	Mode        string                `yaml:"mode" default:"fast"`
+	Ratio       float64               `yaml:"ratio" validate:"min=0,max=1"`
+	Outputs     map[string]testOutput `yaml:"outputs" validate:"required"`
+}
+
+// Validate rejects a mode the tags cannot express.
+func (c testConfig) Validate() error {
+	if c.Mode != "fast" && c.Mode != "slow" {
+		return fmt.Errorf("unknown mode %q", c.Mode)
+	}
+	return nil
+}
+
+func TestDecode(t *testing.T) {
+	input := uuid.New()
+	output := uuid.New()
+	valid := fmt.Sprintf("input_mailbox_uuid: %s\noutputs:\n  a:\n    mailbox_uuid: %s\n", input, output)
+
+	tests := []struct {
+		name    string
+		raw     any
+		want    testConfig
+		wantErr string
+	}{
+		{
+			name: "applies defaults to empty fields",
+			raw:  []byte(valid),
+			want: testConfig{
+				InputConfig: InputConfig{InputMailboxUUID: input, InputMailboxBuffer: 100},
+				Mode:        "fast",
+				Outputs:     map[string]testOutput{"a": {MailboxUUID: output, Tag: "out"}},
+			},
+		},
+		{
+			name: "keeps set fields",
+			raw:  []byte(valid + "input_mailbox_buffer: 5\nmode: slow\nratio: 0.5\n"),
+			want: testConfig{
+				InputConfig: InputConfig{InputMailboxUUID: input, InputMailboxBuffer: 5},
+				Mode:        "slow",
+				Ratio:       0.5,
+				Outputs:     map[string]testOutput{"a": {MailboxUUID: output, Tag: "out"}},
+			},
+		},
+		{name: "not bytes", raw: "input_mailbox_uuid: x", wantErr: "expected []byte"},
+		{name: "malformed YAML", raw: []byte("outputs: ["), wantErr: "failed to unmarshal"},
+		{name: "missing inline field", raw: []byte("outputs:\n  a:\n    mailbox_uuid: " + output.String()), wantErr: "input_mailbox_uuid is required"},
+		{name: "missing map", raw: []byte("input_mailbox_uuid: " + input.String()), wantErr: "outputs is required"},
+		{name: "missing nested field", raw: []byte(valid + "  b:\n    tag: x\n"), wantErr: "outputs.b.mailbox_uuid is required"},
+		{name: "below minimum", raw: []byte(valid + "input_mailbox_buffer: -1\n"), wantErr: "input_mailbox_buffer must be at least 0"},
+		{name: "above maximum", raw: []byte(valid + "ratio: 2\n"), wantErr: "ratio must be at most 1"},
+		{name: "rejected by Validate", raw: []byte(valid + "mode: medium\n"), wantErr: `unknown mode "medium"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := Decode[testConfig](tt.raw)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("returned error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("returned error %v", err)
+			}
+			if !reflect.DeepEqual(config, tt.want) {
+				t.Errorf("decoded %+v, want %+v", config, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalidTags(t *testing.T) {
+	type unknownRule struct {
+		Name string `yaml:"name" validate:"pattern"`
+	}
+	type badBound struct {
+		Count int `yaml:"count" validate:"min=many"`
+	}
+	type badDefault struct {
+		Count int `yaml:"count" default:"many"`
+	}
+
+	tests := []struct {
+		name    string
+		decode  func() error
+		wantErr string
+	}{
+		{"unknown rule", func() error { _, err := Decode[unknownRule]([]byte("name: x")); return err }, `unknown rule "pattern"`},
+		{"non-numeric bound", func() error { _, err := Decode[badBound]([]byte("count: 1")); return err }, "needs a numeric bound"},
+		{"undecodable default", func() error { _, err := Decode[badDefault]([]byte("{}")); return err }, "invalid default for count"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.decode(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("returned error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
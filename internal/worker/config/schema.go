@@ -0,0 +1,116 @@
+package config
+
+import (
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"time"
+)
+
+// SchemaDialect is the JSON Schema dialect of generated schemas.
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema document.
+type Schema map[string]any
+
+var (
+	uuidType     = reflect.TypeOf(uuid.UUID{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// Schema describes the config type as JSON Schema, with its fields named as in YAML and its validate and default
+// tags as constraints and defaults. Checks made by Validate methods are not described.
+func (t Type) Schema() Schema {
+	schema := schemaOf(t.goType)
+	schema["$schema"] = SchemaDialect
+	return schema
+}
+
+// schemaOf describes a Go type as JSON Schema.
+func schemaOf(goType reflect.Type) Schema {
+	switch goType {
+	case uuidType:
+		return Schema{"type": "string", "format": "uuid"}
+	case durationType:
+		return Schema{"type": "string", "description": "A duration such as 500ms or 1m30s."}
+	}
+
+	switch goType.Kind() {
+	case reflect.Pointer:
+		return schemaOf(goType.Elem())
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": schemaOf(goType.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": schemaOf(goType.Elem())}
+	case reflect.Struct:
+		return structSchema(goType)
+	default:
+		return Schema{}
+	}
+}
+
+// structSchema describes a struct type as a JSON Schema object.
+func structSchema(structType reflect.Type) Schema {
+	properties := Schema{}
+	var required []string
+	for _, f := range fields(structType) {
+		property := schemaOf(f.Type)
+
+		// Tags are checked when configs are decoded; an invalid tag is left out of the schema.
+		rules, _ := parseRules(f.Tag.Get("validate"))
+		for _, r := range rules {
+			switch r.name {
+			case "required":
+				required = append(required, f.name)
+				if keyword := lengthKeyword(f.Type, "min"); keyword != "" {
+					property[keyword] = 1
+				}
+			case "min", "max":
+				keyword := lengthKeyword(f.Type, r.name)
+				if keyword == "" {
+					keyword = map[string]string{"min": "minimum", "max": "maximum"}[r.name]
+				}
+				property[keyword] = r.bound
+			}
+		}
+
+		if defaultValue, ok := f.Tag.Lookup("default"); ok {
+			var decoded any
+			if err := yaml.Unmarshal([]byte(defaultValue), &decoded); err == nil {
+				property["default"] = decoded
+			}
+		}
+
+		properties[f.name] = property
+	}
+
+	schema := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// lengthKeyword returns the JSON Schema keyword bounding the length of a string, array or object for the min or max
+// rule, or an empty string for other types.
+func lengthKeyword(goType reflect.Type, ruleName string) string {
+	switch goType.Kind() {
+	case reflect.String:
+		return ruleName + "Length"
+	case reflect.Slice:
+		return ruleName + "Items"
+	case reflect.Map:
+		return ruleName + "Properties"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,34 @@
+package worker
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// EventMessageTag is the tag of messages carrying node events to subscribed mailboxes.
+const EventMessageTag = "node_event"
+
+// EventType identifies the kind of lifecycle event emitted by the node.
+type EventType string
+
+const (
+	WorkerStartedEvent      EventType = "worker_started"
+	WorkerExitedEvent       EventType = "worker_exited"
+	WorkerReconfiguredEvent EventType = "worker_reconfigured"
+	WorkerReadyEvent        EventType = "worker_ready"
+	MailboxCreatedEvent     EventType = "mailbox_created"
+	MailboxRemovedEvent     EventType = "mailbox_removed"
+)
+
+// Event describes a lifecycle change on the node. Workers subscribed through Services receive it as the payload of
+// a Message tagged EventMessageTag.
+type Event struct {
+	Type        EventType `json:"type"`
+	Time        time.Time `json:"time"`
+	WorkerUUID  uuid.UUID `json:"worker_uuid"`
+	WorkerType  string    `json:"worker_type,omitempty"`
+	MailboxUUID uuid.UUID `json:"mailbox_uuid,omitempty"`
+	ExitCode    ExitCode  `json:"exit_code"`
+	Error       string    `json:"error,omitempty"`
+	WillRestart bool      `json:"will_restart,omitempty"`
+}
@@ -0,0 +1,20 @@
+package worker
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrInboxClosed is returned by an Inbox once its mailbox has been removed.
+var ErrInboxClosed = errors.New("inbox closed: mailbox was removed")
+
+// Inbox receives the messages of a mailbox as Message values. Unlike the channel returned by CreateMailbox, an inbox
+// backed by a ring mailbox hands messages over without boxing them. An inbox must be received from by a single
+// goroutine.
+type Inbox interface {
+	// Receive waits for the next message, failing with ErrInboxClosed once the mailbox is removed, or with the
+	// context's error once it is done.
+	Receive(ctx context.Context) (Message, error)
+	// TryReceive returns the next message without waiting, reporting whether there was one.
+	TryReceive() (Message, bool)
+}
@@ -0,0 +1,161 @@
+package worker
+
+import (
+	"fmt"
+	"time"
+)
+
+// OverflowPolicy selects what happens to a message sent to a full mailbox.
+type OverflowPolicy string
+
+const (
+	// OverflowReject fails the send with an error. This is the default. A sender asking to block waits for space
+	// without a limit instead.
+	OverflowReject OverflowPolicy = "reject"
+	// OverflowBlock waits for space, failing the send once the block timeout elapses. A zero timeout waits
+	// without a limit.
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDropOldest discards the oldest queued message to make room for the new one.
+	OverflowDropOldest OverflowPolicy = "drop-oldest"
+	// OverflowDropNewest discards the new message, keeping the queue as it is.
+	OverflowDropNewest OverflowPolicy = "drop-newest"
+	// OverflowConflateByTag keeps only the latest queued message per tag: a new message replaces a queued message
+	// with the same tag in place, whether or not the mailbox is full. If the mailbox is full and no message shares
+	// its tag, the oldest queued message is discarded.
+	OverflowConflateByTag OverflowPolicy = "conflate-by-tag"
+)
+
+// MailboxBackend selects the queue implementation behind a mailbox.
+type MailboxBackend string
+
+const (
+	// BackendQueue supports every overflow policy. This is the default. A mailbox without a control lane under the
+	// reject, block or drop-newest policy is a buffered channel; the others are a mutex guarded queue.
+	BackendQueue MailboxBackend = "queue"
+	// BackendRing is a lock-free multi-producer, single-consumer ring buffer of messages, for high-throughput
+	// streams. Its capacity is rounded up to a power of two of at least two, and it does not support the drop-oldest and
+	// conflate-by-tag policies. Receive from it through an Inbox to avoid boxing each message
+	// into a channel.
+	BackendRing MailboxBackend = "ring"
+)
+
+// Priority selects the lane a message is queued in at its destination mailbox. A mailbox with a control lane
+// delivers every queued control message before its queued data messages, keeping the order of the messages within
+// each lane.
+type Priority uint8
+
+const (
+	// PriorityData is the lane of ordinary messages, subject to the mailbox's overflow policy. This is the default.
+	PriorityData Priority = iota
+	// PriorityControl is the lane of control messages, such as commands to the receiving worker. The control lane
+	// has its own capacity and is not subject to the overflow policy: a send to a full control lane fails, or
+	// waits for space if the sender asks to block. A send to a mailbox created without a control lane fails.
+	PriorityControl
+)
+
+// MailboxOptions configures a mailbox when it is created.
+type MailboxOptions struct {
+	Overflow        OverflowPolicy
+	BlockTimeout    time.Duration
+	Backend         MailboxBackend
+	ControlCapacity int
+}
+
+// MailboxOption sets an option of a mailbox being created.
+type MailboxOption func(options *MailboxOptions)
+
+// WithOverflow sets the policy applied when a message is sent to the mailbox while it is full.
+func WithOverflow(policy OverflowPolicy) MailboxOption {
+	return func(options *MailboxOptions) {
+		options.Overflow = policy
+	}
+}
+
+// WithBlockTimeout bounds how long a send waits for space under the OverflowBlock policy.
+func WithBlockTimeout(timeout time.Duration) MailboxOption {
+	return func(options *MailboxOptions) {
+		options.BlockTimeout = timeout
+	}
+}
+
+// WithBackend selects the queue implementation behind the mailbox.
+func WithBackend(backend MailboxBackend) MailboxOption {
+	return func(options *MailboxOptions) {
+		options.Backend = backend
+	}
+}
+
+// WithControlCapacity gives the mailbox a control lane queuing up to capacity control messages ahead of its data
+// messages. Mailboxes have no control lane by default.
+func WithControlCapacity(capacity int) MailboxOption {
+	return func(options *MailboxOptions) {
+		options.ControlCapacity = capacity
+	}
+}
+
+// NewMailboxOptions applies the options over the defaults.
+func NewMailboxOptions(opts ...MailboxOption) MailboxOptions {
+	options := MailboxOptions{Overflow: OverflowReject, Backend: BackendQueue}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// Validate checks the options for unknown policies and invalid values.
+func (o MailboxOptions) Validate() error {
+	switch o.Overflow {
+	case OverflowReject, OverflowBlock, OverflowDropOldest, OverflowDropNewest, OverflowConflateByTag:
+	default:
+		return fmt.Errorf("unknown overflow policy %q", o.Overflow)
+	}
+	if o.BlockTimeout < 0 {
+		return fmt.Errorf("block_timeout must not be negative")
+	}
+	if o.ControlCapacity < 0 {
+		return fmt.Errorf("control capacity must not be negative")
+	}
+	switch o.Backend {
+	case BackendQueue:
+	case BackendRing:
+		if o.Overflow == OverflowDropOldest || o.Overflow == OverflowConflateByTag {
+			return fmt.Errorf("overflow policy %q is not supported by the %q backend", o.Overflow, o.Backend)
+		}
+	default:
+		return fmt.Errorf("unknown mailbox backend %q", o.Backend)
+	}
+	return nil
+}
+
+// OverflowConfig is the overflow section of a worker's mailbox config.
+type OverflowConfig struct {
+	Policy       OverflowPolicy `yaml:"policy"`
+	BlockTimeout time.Duration  `yaml:"block_timeout"`
+}
+
+// Options converts the config into mailbox options. An empty policy keeps the default.
+func (c OverflowConfig) Options() []MailboxOption {
+	var opts []MailboxOption
+	if c.Policy != "" {
+		opts = append(opts, WithOverflow(c.Policy))
+	}
+	if c.BlockTimeout != 0 {
+		opts = append(opts, WithBlockTimeout(c.BlockTimeout))
+	}
+	return opts
+}
+
+// Validate checks the config for unknown policies and invalid values.
+func (c OverflowConfig) Validate() error {
+	return NewMailboxOptions(c.Options()...).Validate()
+}
+
+// MailboxConfigOptions converts the overflow section and backend of a worker's mailbox config into mailbox options.
+// An empty backend keeps the default.
+func MailboxConfigOptions(overflow OverflowConfig, backend MailboxBackend) []MailboxOption {
+	opts := overflow.Options()
+	if backend != "" {
+		opts = append(opts, WithBackend(backend))
+	}
+	return opts
+}
@@ -0,0 +1,46 @@
+package worker
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+)
+
+// Topic is a named publish/subscribe channel carrying payloads of type T. Messages published to a topic are
+// delivered to every mailbox subscribed to it at the time, tagged with the topic's name.
+type Topic[T any] struct {
+	Name string
+}
+
+// NewTopic creates a handle to the named topic.
+func NewTopic[T any](name string) Topic[T] {
+	return Topic[T]{Name: name}
+}
+
+// Publish sends the payload to every subscriber of the topic.
+func (t Topic[T]) Publish(services Services, payload T, block bool) error {
+	return services.Publish(t.Name, Message{Tag: t.Name, Payload: payload}, block)
+}
+
+// Continue publishes the payload as a message derived from m, keeping its trace, creation time and headers.
+func (t Topic[T]) Continue(services Services, m Message, payload T, block bool) error {
+	return services.Publish(t.Name, m.Continue(t.Name, payload), block)
+}
+
+// Subscribe delivers the topic's messages to the mailbox.
+func (t Topic[T]) Subscribe(services Services, mailboxUUID uuid.UUID) error {
+	return services.Subscribe(t.Name, mailboxUUID)
+}
+
+// Unsubscribe stops delivering the topic's messages to the mailbox.
+func (t Topic[T]) Unsubscribe(services Services, mailboxUUID uuid.UUID) {
+	services.Unsubscribe(t.Name, mailboxUUID)
+}
+
+// Payload returns the payload of a message received from the topic.
+func (t Topic[T]) Payload(message Message) (T, error) {
+	payload, ok := message.Payload.(T)
+	if !ok {
+		return payload, fmt.Errorf("message on topic %q has payload of type %T, not %T", t.Name, message.Payload, payload)
+	}
+	return payload, nil
+}
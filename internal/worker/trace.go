@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"github.com/google/uuid"
+	"sync/atomic"
+	"time"
+)
+
+// MaxTraceSpans bounds the number of spans carried by a message. The oldest spans are discarded beyond it.
+const MaxTraceSpans = 32
+
+// minTraceBuffer is the number of spans allocated for a new trace buffer.
+const minTraceBuffer = 4
+
+// Span records one hop of a message: when a worker sent it to a mailbox, and when the message left that mailbox's
+// queue for the mailbox's worker.
+type Span struct {
+	Worker     uuid.UUID `json:"worker"`
+	Mailbox    uuid.UUID `json:"mailbox"`
+	SentAt     time.Time `json:"sent_at"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// Latency returns the time the message took to reach the mailbox's worker, or zero if it has not yet.
+func (s Span) Latency() time.Duration {
+	if s.ReceivedAt.IsZero() {
+		return 0
+	}
+	return s.ReceivedAt.Sub(s.SentAt)
+}
+
+// Continue creates a message derived from m, carrying over its trace, origin, creation time and headers, so that a
+// worker transforming a message keeps it traceable end to end.
+func (m Message) Continue(tag string, payload any) Message {
+	return Message{
+		Tag:         tag,
+		Payload:     payload,
+		Origin:      m.Origin,
+		CreatedAt:   m.CreatedAt,
+		Headers:     m.Headers,
+		Trace:       m.Trace,
+		traceBuffer: m.traceBuffer,
+	}
+}
+
+// traceBuffer holds the spans of the traces of a message and the messages derived from it. Messages sent one after
+// another share the buffer, each trace extending the last, so a span is only copied when two messages extend the
+// same trace. end is the end of the longest trace in the buffer: only a trace ending there may claim the next slot.
+type traceBuffer struct {
+	spans []Span
+	end   atomic.Int64
+}
+
+// offset returns where the trace starts in the buffer, and false if the trace is not a window of it.
+func (b *traceBuffer) offset(trace []Span) (int, bool) {
+	start := len(b.spans) - cap(trace)
+	if len(trace) == 0 || start < 0 || &b.spans[start] != &trace[0] {
+		return 0, false
+	}
+	return start, true
+}
+
+// appendSpan appends the span to the trace, in place if the message's trace ends where its buffer was last
+// extended, and otherwise by copying the trace into a new buffer, so that traces never share an open span.
+func (m *Message) appendSpan(span Span) {
+	if b := m.traceBuffer; b != nil {
+		if start, ok := b.offset(m.Trace); ok {
+			end := start + len(m.Trace)
+			if end < len(b.spans) && b.end.CompareAndSwap(int64(end), int64(end+1)) {
+				b.spans[end] = span
+				m.Trace = b.spans[max(start, end+1-MaxTraceSpans) : end+1]
+				return
+			}
+		}
+	}
+
+	trace := m.Trace
+	if len(trace) >= MaxTraceSpans {
+		trace = trace[len(trace)-MaxTraceSpans+1:]
+	}
+	b := &traceBuffer{spans: make([]Span, min(max(2*(len(trace)+1), minTraceBuffer), 2*MaxTraceSpans))}
+	copy(b.spans, trace)
+	b.spans[len(trace)] = span
+	b.end.Store(int64(len(trace) + 1))
+	m.Trace = b.spans[:len(trace)+1]
+	m.traceBuffer = b
+}
+
+// StartSpan appends a span for a send by the worker to the mailbox. Called by the node when the message is sent.
+func (m *Message) StartSpan(workerUUID uuid.UUID, mailboxUUID uuid.UUID, sentAt time.Time) {
+	m.appendSpan(Span{
+		Worker:  workerUUID,
+		Mailbox: mailboxUUID,
+		SentAt:  sentAt,
+	})
+}
+
+// FinishSpan completes the open span for the mailbox, returning it and whether there was one. Called by the node
+// when the message leaves the mailbox's queue.
+func (m *Message) FinishSpan(mailboxUUID uuid.UUID, receivedAt time.Time) (Span, bool) {
+	if len(m.Trace) == 0 {
+		return Span{}, false
+	}
+	last := &m.Trace[len(m.Trace)-1]
+	if last.Mailbox != mailboxUUID || !last.ReceivedAt.IsZero() {
+		return Span{}, false
+	}
+	last.ReceivedAt = receivedAt
+	return *last, true
+}
@@ -0,0 +1,199 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/worker/config"
+	"github.com/google/uuid"
+	"reflect"
+	"sync/atomic"
+)
+
+// MessagePolicy selects what a worker does with a message it cannot handle.
+type MessagePolicy string
+
+const (
+	// MessageFail stops the worker with a runtime error.
+	MessageFail MessagePolicy = "fail"
+	// MessageSkip discards the message.
+	MessageSkip MessagePolicy = "skip"
+	// MessageDeadLetter hands the message to the node's dead-letter queue.
+	MessageDeadLetter MessagePolicy = "dead-letter"
+)
+
+// Validate checks the policy is known.
+func (p MessagePolicy) Validate() error {
+	switch p {
+	case MessageFail, MessageSkip, MessageDeadLetter:
+		return nil
+	default:
+		return fmt.Errorf("unknown message policy %q", p)
+	}
+}
+
+// Handle applies the policy to a message received on the mailbox, returning the reason if the worker should fail.
+func (p MessagePolicy) Handle(services Services, mailboxUUID uuid.UUID, message Message, reason error) error {
+	switch p {
+	case MessageSkip:
+		return nil
+	case MessageDeadLetter:
+		services.DeadLetter(mailboxUUID, message, reason)
+		return nil
+	default:
+		return reason
+	}
+}
+
+// OutputMapping is the destination of the messages of an input tag, and the tag they are sent with.
+type OutputMapping struct {
+	MailboxUUID uuid.UUID `yaml:"mailbox_uuid" validate:"required"`
+	Tag         string    `yaml:"tag"`
+}
+
+// TransformerConfig is the config of a Transformer. Worker configs embed it with the yaml inline flag, next to any
+// fields of their own.
+type TransformerConfig struct {
+	InputMailboxUUID     uuid.UUID                `yaml:"input_mailbox_uuid" validate:"required"`
+	InputMailboxBuffer   int                      `yaml:"input_mailbox_buffer" validate:"min=0"`
+	InputMailboxOverflow OverflowConfig           `yaml:"input_mailbox_overflow"`
+	InputMailboxBackend  MailboxBackend           `yaml:"input_mailbox_backend"`
+	InputOutputMapping   map[string]OutputMapping `yaml:"input_output_mapping" validate:"required"`
+	BlockingSend         bool                     `yaml:"blocking_send"`
+	// UnmappedTagPolicy applies to messages whose tag has no output mapping.
+	UnmappedTagPolicy MessagePolicy `yaml:"unmapped_tag_policy" default:"dead-letter"`
+	// BadPayloadPolicy applies to messages whose payload is not of the transformer's input type.
+	BadPayloadPolicy MessagePolicy `yaml:"bad_payload_policy" default:"fail"`
+}
+
+// Validate checks the input mailbox options and the policies of the config.
+func (c TransformerConfig) Validate() error {
+	if err := NewMailboxOptions(c.inputMailboxOptions()...).Validate(); err != nil {
+		return fmt.Errorf("invalid input mailbox: %w", err)
+	}
+	if err := c.UnmappedTagPolicy.Validate(); err != nil {
+		return fmt.Errorf("invalid unmapped_tag_policy: %w", err)
+	}
+	if err := c.BadPayloadPolicy.Validate(); err != nil {
+		return fmt.Errorf("invalid bad_payload_policy: %w", err)
+	}
+	return nil
+}
+
+func (c TransformerConfig) inputMailboxOptions() []MailboxOption {
+	return MailboxConfigOptions(c.InputMailboxOverflow, c.InputMailboxBackend)
+}
+
+// Info reports the mailboxes a transformer creates and sends to under the config.
+func (c TransformerConfig) Info() ConfigInfo {
+	info := ConfigInfo{InputMailboxes: []uuid.UUID{c.InputMailboxUUID}}
+	for _, mapping := range c.InputOutputMapping {
+		info.OutputMailboxes = append(info.OutputMailboxes, mapping.MailboxUUID)
+	}
+	return info
+}
+
+// Transformer is the loop of a worker that converts the payload of each message from its input mailbox, of type In,
+// into an Out and sends it on to the mailbox mapped to the message's tag. Workers embed it and call Run with their
+// transform function; the embedded InspectConfig and Reconfigure read the TransformerConfig part of their config,
+// so the mappings, send mode and policies can be changed while the worker runs.
+type Transformer[In, Out any] struct {
+	config atomic.Pointer[TransformerConfig]
+}
+
+// Run decodes the TransformerConfig within the raw config, creates the input mailbox, reports the worker ready and
+// transforms its messages until ctx is done. It receives through an inbox, so a ring backed input mailbox hands
+// messages over without boxing them. An error returned by transform stops the worker.
+func (t *Transformer[In, Out]) Run(ctx context.Context, rawConfig any, services Services, transform func(payload In) (Out, error)) (ExitCode, error) {
+	config, err := t.parseConfig(rawConfig)
+	if err != nil {
+		return RuntimeErrorExit, fmt.Errorf("failed to parse raw config: %w", err)
+	}
+	t.config.Store(&config)
+	defer t.config.Store(nil)
+
+	inbox, err := services.CreateInbox(config.InputMailboxUUID, config.InputMailboxBuffer, config.inputMailboxOptions()...)
+	defer services.RemoveMailbox(config.InputMailboxUUID)
+	if err != nil {
+		return RuntimeErrorExit, fmt.Errorf("failed to create input mailbox: %w", err)
+	}
+	services.Ready()
+
+	for {
+		message, err := inbox.Receive(ctx)
+		if err != nil {
+			switch {
+			case ctx.Err() != nil:
+				return NormalExit, nil
+			case errors.Is(err, ErrInboxClosed):
+				return PrematureExit, fmt.Errorf("input mailbox closed")
+			default:
+				return RuntimeErrorExit, fmt.Errorf("failed to receive message: %w", err)
+			}
+		}
+
+		// Route by the current config, which Reconfigure may have replaced.
+		current := t.config.Load()
+		mappedOutput, ok := current.InputOutputMapping[message.Tag]
+		if !ok {
+			reason := fmt.Errorf("destination mapping not found for tag: %s", message.Tag)
+			if err := current.UnmappedTagPolicy.Handle(services, config.InputMailboxUUID, message, reason); err != nil {
+				return RuntimeErrorExit, err
+			}
+			continue
+		}
+
+		payload, ok := message.Payload.(In)
+		if !ok {
+			reason := fmt.Errorf("message payload is of type %T, not %s", message.Payload, reflect.TypeFor[In]())
+			if err := current.BadPayloadPolicy.Handle(services, config.InputMailboxUUID, message, reason); err != nil {
+				return RuntimeErrorExit, err
+			}
+			continue
+		}
+
+		output, err := transform(payload)
+		if err != nil {
+			return RuntimeErrorExit, fmt.Errorf("failed to transform payload: %w", err)
+		}
+
+		if err := services.SendMessage(mappedOutput.MailboxUUID, message.Continue(mappedOutput.Tag, output), current.BlockingSend); err != nil {
+			return RuntimeErrorExit, fmt.Errorf("failed to send message: %w", err)
+		}
+	}
+}
+
+// Reconfigure swaps the mappings, send mode and policies of the running transformer. Changes to the input mailbox
+// require a restart.
+func (t *Transformer[In, Out]) Reconfigure(rawConfig any) error {
+	config, err := t.parseConfig(rawConfig)
+	if err != nil {
+		return err
+	}
+
+	current := t.config.Load()
+	if current == nil {
+		return fmt.Errorf("worker is not running: %w", ErrRestartRequired)
+	}
+	if config.InputMailboxUUID != current.InputMailboxUUID || config.InputMailboxBuffer != current.InputMailboxBuffer ||
+		config.InputMailboxOverflow != current.InputMailboxOverflow || config.InputMailboxBackend != current.InputMailboxBackend {
+		return fmt.Errorf("input mailbox changed: %w", ErrRestartRequired)
+	}
+
+	t.config.Store(&config)
+	return nil
+}
+
+// InspectConfig validates the TransformerConfig within the raw config and reports the mailboxes the transformer
+// creates and sends to.
+func (t *Transformer[In, Out]) InspectConfig(rawConfig any) (ConfigInfo, error) {
+	config, err := t.parseConfig(rawConfig)
+	if err != nil {
+		return ConfigInfo{}, err
+	}
+	return config.Info(), nil
+}
+
+func (t *Transformer[In, Out]) parseConfig(rawConfig any) (TransformerConfig, error) {
+	return config.Decode[TransformerConfig](rawConfig)
+}
@@ -2,7 +2,10 @@ package worker
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
+	"time"
 )
 
 // ExitCode represents the exit status of a worker. It is used to communicate the reason for the worker's termination to the node.
@@ -15,20 +18,134 @@ const (
 	PanicExit
 )
 
+// String returns a human-readable name for the exit code.
+func (c ExitCode) String() string {
+	switch c {
+	case NormalExit:
+		return "NormalExit"
+	case PrematureExit:
+		return "PrematureExit"
+	case RuntimeErrorExit:
+		return "RuntimeErrorExit"
+	case PanicExit:
+		return "PanicExit"
+	default:
+		return fmt.Sprintf("ExitCode(%d)", int(c))
+	}
+}
+
+// MarshalText encodes the exit code by name.
+func (c ExitCode) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText decodes an exit code from its name.
+func (c *ExitCode) UnmarshalText(text []byte) error {
+	for _, code := range []ExitCode{NormalExit, PrematureExit, RuntimeErrorExit, PanicExit} {
+		if code.String() == string(text) {
+			*c = code
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown exit code %q", text)
+}
+
 // Services defines the services (interface) that a worker can use to interact with the system.
 type Services interface {
 	SendMessage(destinationMailboxUUID uuid.UUID, message Message, block bool) error
-	CreateMailbox(mailboxUUID uuid.UUID, bufferSize int) (<-chan any, error)
+	// SendControl sends the message like SendMessage, in the control lane of the destination mailbox, so it is
+	// delivered ahead of the data messages already queued there.
+	SendControl(destinationMailboxUUID uuid.UUID, message Message, block bool) error
+	CreateMailbox(mailboxUUID uuid.UUID, bufferSize int, opts ...MailboxOption) (<-chan any, error)
+	// CreateInbox creates a mailbox like CreateMailbox, receiving from it through an Inbox instead of a channel.
+	CreateInbox(mailboxUUID uuid.UUID, bufferSize int, opts ...MailboxOption) (Inbox, error)
 	RemoveMailbox(mailboxUUID uuid.UUID)
+	SubscribeEvents(mailboxUUID uuid.UUID) error
+	UnsubscribeEvents(mailboxUUID uuid.UUID)
+
+	// Publish delivers the message to every mailbox subscribed to the topic. A topic carries a single payload type,
+	// fixed by its first publish.
+	Publish(topic string, message Message, block bool) error
+	// Subscribe delivers the topic's messages to the mailbox until it is unsubscribed or the worker exits.
+	Subscribe(topic string, mailboxUUID uuid.UUID) error
+	Unsubscribe(topic string, mailboxUUID uuid.UUID)
+
+	// Request sends the message to the mailbox and waits for the receiving worker to Reply to it, failing once ctx
+	// is done.
+	Request(ctx context.Context, destinationMailboxUUID uuid.UUID, message Message) (Message, error)
+	// Reply sends the reply to the worker that made the request.
+	Reply(request Message, reply Message) error
+	// DeadLetter hands a message the worker cannot handle, received on or meant for the mailbox, to the node's
+	// dead-letter queue, where it can be inspected and replayed.
+	DeadLetter(mailboxUUID uuid.UUID, message Message, reason error)
+
+	// Ready reports that the worker has finished starting, such as creating its mailboxes and subscribing to its
+	// streams, so that a start_worker instruction waiting for it can complete. Calls after the first have no effect.
+	Ready()
 }
 
 // Message represents a message that can be sent or received by a worker. Identifications of source and purpose are done via tags.
+// The envelope fields after Payload are stamped by the node's services when the message is sent.
 type Message struct {
 	Tag     string
 	Payload interface{}
+
+	// Source is the UUID of the worker that sent the message.
+	Source uuid.UUID
+	// SourceMailbox is the mailbox the sender receives on, i.e. the first mailbox it created. It is nil for senders
+	// without a mailbox, such as websocket workers.
+	SourceMailbox uuid.UUID
+	// Sequence counts the messages the source has sent to the destination mailbox, starting from 1, so a consumer
+	// can detect gaps. It continues across restarts of the source. Replies are not sequenced and carry 0.
+	Sequence uint64
+	// Origin is the worker that produced the message and started its trace. It is set on the first send and carried
+	// forward by Continue, so it survives the oldest spans being discarded.
+	Origin uuid.UUID
+	// CreatedAt is when the message was produced. The send time is used unless the sender sets it.
+	CreatedAt time.Time
+	// Headers carries optional user metadata.
+	Headers map[string]string
+	// Trace holds a span for every hop of the message, carried forward by Continue.
+	Trace []Span
+	// traceBuffer is the buffer Trace was last extended in, if any, letting sends extend it without copying.
+	traceBuffer *traceBuffer
+	// ReplyTo is the mailbox awaiting a reply to the message, set on requests sent with Services.Request.
+	ReplyTo uuid.UUID
+	// CorrelationID matches a reply to its request.
+	CorrelationID uuid.UUID
+	// Priority selects the lane the message is queued in at the destination mailbox, set by Services.SendControl.
+	Priority Priority
+}
+
+// IsRequest reports whether the sender of the message is waiting for a reply.
+func (m Message) IsRequest() bool {
+	return m.ReplyTo != uuid.Nil
 }
 
 // Worker interface. Every worker that wants to be deployed by the node must implement this interface.
 type Worker interface {
 	Run(ctx context.Context, rawConfig any, services Services) (ExitCode, error)
 }
+
+// ConfigInspector is implemented by workers that can check a raw config without running, so that tasks can be
+// validated before any of their instructions are applied.
+type ConfigInspector interface {
+	InspectConfig(rawConfig any) (ConfigInfo, error)
+}
+
+// ErrRestartRequired is returned, wrapped, by Reconfigure when the new config can only be applied by restarting the
+// worker, such as a change to its input mailbox.
+var ErrRestartRequired = errors.New("config change requires a restart")
+
+// Reconfigurable is implemented by workers that can take a new raw config while running. The node calls Reconfigure
+// for the reconfigure_worker instruction and restarts the worker with the new config if it returns an error
+// wrapping ErrRestartRequired. Reconfigure must not block, and leaves the running config in place on error.
+type Reconfigurable interface {
+	Reconfigure(rawConfig any) error
+}
+
+// ConfigInfo lists the mailboxes a worker creates and the mailboxes it sends to under a given config.
+type ConfigInfo struct {
+	InputMailboxes  []uuid.UUID
+	OutputMailboxes []uuid.UUID
+}
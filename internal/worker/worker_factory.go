@@ -2,15 +2,18 @@ package worker
 
 import (
 	"fmt"
+	"github.com/PhillipMichelsen/Tessera/internal/worker/config"
 )
 
 type Factory struct {
 	workerCreationFunctions map[string]func() Worker
+	configTypes             map[string]config.Type
 }
 
 func NewFactory() *Factory {
 	return &Factory{
 		workerCreationFunctions: make(map[string]func() Worker),
+		configTypes:             make(map[string]config.Type),
 	}
 }
 
@@ -20,6 +23,9 @@ func AggregateFactories(factories ...*Factory) *Factory {
 		for workerType, creationFunc := range factory.workerCreationFunctions {
 			newFactory.RegisterWorkerCreationFunction(workerType, creationFunc)
 		}
+		for workerType, configType := range factory.configTypes {
+			newFactory.RegisterConfigType(workerType, configType)
+		}
 	}
 	return newFactory
 }
@@ -28,6 +34,12 @@ func (wf *Factory) RegisterWorkerCreationFunction(workerType string, creationFun
 	wf.workerCreationFunctions[workerType] = creationFunc
 }
 
+// RegisterConfigType records the config type of a worker type, so its configs can be checked and described
+// without running a worker.
+func (wf *Factory) RegisterConfigType(workerType string, configType config.Type) {
+	wf.configTypes[workerType] = configType
+}
+
 func (wf *Factory) InstantiateWorker(workerType string) (Worker, error) {
 	creationFunc, exists := wf.workerCreationFunctions[workerType]
 	if !exists {
@@ -35,3 +47,22 @@ func (wf *Factory) InstantiateWorker(workerType string) (Worker, error) {
 	}
 	return creationFunc(), nil
 }
+
+// CheckConfig decodes and validates a raw config against the config type registered for the worker type. Configs
+// of worker types without a registered config type are not checked.
+func (wf *Factory) CheckConfig(workerType string, rawConfig any) error {
+	configType, exists := wf.configTypes[workerType]
+	if !exists {
+		return nil
+	}
+	return configType.Check(rawConfig)
+}
+
+// ConfigSchemas returns the JSON Schema of the config of every worker type with a registered config type.
+func (wf *Factory) ConfigSchemas() map[string]config.Schema {
+	schemas := make(map[string]config.Schema, len(wf.configTypes))
+	for workerType, configType := range wf.configTypes {
+		schemas[workerType] = configType.Schema()
+	}
+	return schemas
+}
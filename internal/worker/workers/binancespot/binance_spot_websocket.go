@@ -5,12 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"time"
 
 	"github.com/PhillipMichelsen/Tessera/internal/models"
 	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/PhillipMichelsen/Tessera/internal/worker/config"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
-	"gopkg.in/yaml.v3"
 )
 
 type BinanceSpotWebsocketStreamMessage struct {
@@ -20,17 +21,24 @@ type BinanceSpotWebsocketStreamMessage struct {
 
 // BinanceSpotWebsocketWorkerConfig defines the YAML configuration.
 type BinanceSpotWebsocketWorkerConfig struct {
-	BaseURL              string `yaml:"base_url"`
+	BaseURL              string `yaml:"base_url" validate:"required"`
 	StreamsOutputMapping map[string]struct {
-		MailboxUUID uuid.UUID `yaml:"mailbox_uuid"`
+		MailboxUUID uuid.UUID `yaml:"mailbox_uuid" validate:"required"`
 		Tag         string    `yaml:"tag"`
-	} `yaml:"streams_output_mapping"`
+	} `yaml:"streams_output_mapping" validate:"required"`
 	BlockingSend bool `yaml:"blocking_send"`
 }
 
 // BinanceSpotWebsocketWorker implements the worker.Worker interface.
 type BinanceSpotWebsocketWorker struct{}
 
+// websocketRead is a frame read from the websocket, with the time it was read, which becomes the creation time of
+// the message carrying it so that traces start at the read.
+type websocketRead struct {
+	data   []byte
+	readAt time.Time
+}
+
 // Run reads the YAML config, connects to the Binance websocket, subscribes to the streams,
 // and routes each received message to the configured destination mailboxes.
 func (w *BinanceSpotWebsocketWorker) Run(ctx context.Context, rawConfig any, services worker.Services) (worker.ExitCode, error) {
@@ -68,9 +76,10 @@ func (w *BinanceSpotWebsocketWorker) Run(ctx context.Context, rawConfig any, ser
 	if err = conn.WriteJSON(subscribeMsg); err != nil {
 		return worker.RuntimeErrorExit, fmt.Errorf("failed to send subscription message: %w", err)
 	}
+	services.Ready()
 
 	// Create channels for messages and errors.
-	msgCh := make(chan []byte)
+	msgCh := make(chan websocketRead)
 	errCh := make(chan error)
 
 	// Launch a goroutine that reads from the websocket.
@@ -81,7 +90,7 @@ func (w *BinanceSpotWebsocketWorker) Run(ctx context.Context, rawConfig any, ser
 				errCh <- err
 				return
 			}
-			msgCh <- message
+			msgCh <- websocketRead{data: message, readAt: time.Now()}
 		}
 	}()
 
@@ -91,7 +100,8 @@ func (w *BinanceSpotWebsocketWorker) Run(ctx context.Context, rawConfig any, ser
 			return worker.NormalExit, nil
 		case err := <-errCh:
 			return worker.RuntimeErrorExit, fmt.Errorf("failed to read message: %w", err)
-		case message := <-msgCh:
+		case read := <-msgCh:
+			message := read.data
 			var msg BinanceSpotWebsocketStreamMessage
 			if err := json.Unmarshal(message, &msg); err != nil {
 				return worker.RuntimeErrorExit, fmt.Errorf("failed to unmarshal message: %w", err)
@@ -113,8 +123,9 @@ func (w *BinanceSpotWebsocketWorker) Run(ctx context.Context, rawConfig any, ser
 			}
 
 			if err := services.SendMessage(output.MailboxUUID, worker.Message{
-				Tag:     output.Tag,
-				Payload: serializedJSON,
+				Tag:       output.Tag,
+				Payload:   serializedJSON,
+				CreatedAt: read.readAt,
 			}, cfg.BlockingSend); err != nil {
 				return worker.RuntimeErrorExit, fmt.Errorf("failed to send message: %w", err)
 			}
@@ -122,23 +133,20 @@ func (w *BinanceSpotWebsocketWorker) Run(ctx context.Context, rawConfig any, ser
 	}
 }
 
-func (w *BinanceSpotWebsocketWorker) parseRawConfig(rawConfig any) (BinanceSpotWebsocketWorkerConfig, error) {
-	configBytes, ok := rawConfig.([]byte)
-	if !ok {
-		return BinanceSpotWebsocketWorkerConfig{}, fmt.Errorf("config is not in the expected []byte format")
-	}
-
-	var config BinanceSpotWebsocketWorkerConfig
-	if err := yaml.Unmarshal(configBytes, &config); err != nil {
-		return BinanceSpotWebsocketWorkerConfig{}, fmt.Errorf("failed to unmarshal configuration: %w", err)
+// InspectConfig validates the raw config and reports the mailboxes the worker creates and sends to.
+func (w *BinanceSpotWebsocketWorker) InspectConfig(rawConfig any) (worker.ConfigInfo, error) {
+	config, err := w.parseRawConfig(rawConfig)
+	if err != nil {
+		return worker.ConfigInfo{}, err
 	}
 
-	if config.BaseURL == "" {
-		return BinanceSpotWebsocketWorkerConfig{}, fmt.Errorf("base_url is required in configuration")
-	}
-	if len(config.StreamsOutputMapping) == 0 {
-		return BinanceSpotWebsocketWorkerConfig{}, fmt.Errorf("at least one stream must be provided in configuration")
+	var info worker.ConfigInfo
+	for _, mapping := range config.StreamsOutputMapping {
+		info.OutputMailboxes = append(info.OutputMailboxes, mapping.MailboxUUID)
 	}
+	return info, nil
+}
 
-	return config, nil
+func (w *BinanceSpotWebsocketWorker) parseRawConfig(rawConfig any) (BinanceSpotWebsocketWorkerConfig, error) {
+	return config.Decode[BinanceSpotWebsocketWorkerConfig](rawConfig)
 }
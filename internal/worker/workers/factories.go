@@ -2,6 +2,7 @@ package workers
 
 import (
 	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/PhillipMichelsen/Tessera/internal/worker/config"
 	binancespot "github.com/PhillipMichelsen/Tessera/internal/worker/workers/binancespot"
 	mexcspot "github.com/PhillipMichelsen/Tessera/internal/worker/workers/mexcspot"
 	standard "github.com/PhillipMichelsen/Tessera/internal/worker/workers/standard"
@@ -13,9 +14,11 @@ func NewPrebuiltStandardWorkersFactory() *worker.Factory {
 	factory.RegisterWorkerCreationFunction("StandardOutput", func() worker.Worker {
 		return &standard.StandardOutputWorker{}
 	})
+	factory.RegisterConfigType("StandardOutput", config.TypeOf[standard.StandardOutputConfig]())
 	factory.RegisterWorkerCreationFunction("Broadcast", func() worker.Worker {
 		return &standard.BroadcastWorker{}
 	})
+	factory.RegisterConfigType("Broadcast", config.TypeOf[standard.BroadcastWorkerConfig]())
 	// Add more worker types here as needed.
 
 	return factory
@@ -26,18 +29,23 @@ func NewPrebuiltBinanceSpotWorkersFactory() *worker.Factory {
 	factory.RegisterWorkerCreationFunction("BinanceSpotWebsocket", func() worker.Worker {
 		return &binancespot.BinanceSpotWebsocketWorker{}
 	})
+	factory.RegisterConfigType("BinanceSpotWebsocket", config.TypeOf[binancespot.BinanceSpotWebsocketWorkerConfig]())
 	factory.RegisterWorkerCreationFunction("BinanceSpotKlineToOHLCV", func() worker.Worker {
 		return &binancespot.BinanceSpotKlineToOHLCVWorker{}
 	})
+	factory.RegisterConfigType("BinanceSpotKlineToOHLCV", config.TypeOf[binancespot.BinanceSpotKlineToOHLCVConfig]())
 	factory.RegisterWorkerCreationFunction("BinanceSpotBookTickerToBookTicker", func() worker.Worker {
 		return &binancespot.BinanceSpotBookTickerToBookTickerWorker{}
 	})
+	factory.RegisterConfigType("BinanceSpotBookTickerToBookTicker", config.TypeOf[binancespot.BinanceSpotBookTickerToBookTickerConfig]())
 	factory.RegisterWorkerCreationFunction("BinanceSpotDepthToOrderBookSnapshot", func() worker.Worker {
 		return &binancespot.BinanceSpotDepthToOrderBookWorker{}
 	})
+	factory.RegisterConfigType("BinanceSpotDepthToOrderBookSnapshot", config.TypeOf[binancespot.BinanceSpotDepthToOrderBookConfig]())
 	factory.RegisterWorkerCreationFunction("BinanceSpotDepthUpdateToOrderBookUpdate", func() worker.Worker {
 		return &binancespot.BinanceSpotDepthUpdateToOrderBookWorker{}
 	})
+	factory.RegisterConfigType("BinanceSpotDepthUpdateToOrderBookUpdate", config.TypeOf[binancespot.BinanceSpotDepthUpdateToOrderBookConfig]())
 	// Add more worker types here as needed.
 
 	return factory
@@ -48,9 +56,11 @@ func NewPrebuiltMEXCSpotWorkersFactory() *worker.Factory {
 	factory.RegisterWorkerCreationFunction("MEXCSpotWebsocket", func() worker.Worker {
 		return &mexcspot.MEXCSpotWebsocketWorker{}
 	})
+	factory.RegisterConfigType("MEXCSpotWebsocket", config.TypeOf[mexcspot.MEXCSpotWebsocketWorkerConfig]())
 	factory.RegisterWorkerCreationFunction("MEXCSpotBookTickerToBookTicker", func() worker.Worker {
 		return &mexcspot.MEXCSpotBookTickerToBookTickerWorker{}
 	})
+	factory.RegisterConfigType("MEXCSpotBookTickerToBookTicker", config.TypeOf[mexcspot.MEXCSpotBookTickerToBookTickerConfig]())
 	// Add more worker types here as needed.
 
 	return factory
@@ -61,6 +71,7 @@ func NewStrategyWorkersFactory() *worker.Factory {
 	factory.RegisterWorkerCreationFunction("CrossMarketSpotArbitrageStrategy", func() worker.Worker {
 		return &strategy.CrossMarketSpotArbitrageStrategyWorker{}
 	})
+	factory.RegisterConfigType("CrossMarketSpotArbitrageStrategy", config.TypeOf[strategy.CrossMarketSpotArbitrageStrategyConfig]())
 
 	return factory
 }
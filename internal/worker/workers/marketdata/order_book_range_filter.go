@@ -5,114 +5,63 @@ import (
 	"fmt"
 	"github.com/PhillipMichelsen/Tessera/internal/models"
 	"github.com/PhillipMichelsen/Tessera/internal/worker"
-	"github.com/google/uuid"
-	"gopkg.in/yaml.v3"
+	"github.com/PhillipMichelsen/Tessera/internal/worker/config"
+	"sync/atomic"
 )
 
 // OrderBookRangeFilterConfig represents the YAML configuration for the worker.
 type OrderBookRangeFilterConfig struct {
-	InputMailboxUUID   uuid.UUID `yaml:"input_mailbox_uuid"`
-	InputMailboxBuffer int       `yaml:"input_mailbox_buffer"`
-	InputOutputMapping map[string]struct {
-		MailboxUUID uuid.UUID `yaml:"mailbox_uuid"`
-		Tag         string    `yaml:"tag"`
-	} `yaml:"input_output_mapping"`
-	BlockingSend    bool    `yaml:"blocking_send"`
-	RangePercentage float64 `yaml:"range_percentage"` // n% range filter
+	worker.TransformerConfig `yaml:",inline"`
+	RangePercentage          float64 `yaml:"range_percentage" validate:"required,min=0"` // n% range filter
 }
 
-// OrderBookRangeFilterWorker implements the worker.Worker interface.
-type OrderBookRangeFilterWorker struct{}
+// OrderBookRangeFilterWorker implements the worker.Worker interface. Its mappings, send mode, policies and range can
+// be reconfigured while it runs.
+type OrderBookRangeFilterWorker struct {
+	worker.Transformer[models.OrderBook, models.OrderBook]
+	config atomic.Pointer[OrderBookRangeFilterConfig]
+}
 
 func (w *OrderBookRangeFilterWorker) Run(ctx context.Context, rawConfig any, services worker.Services) (worker.ExitCode, error) {
 	config, err := w.parseRawConfig(rawConfig)
 	if err != nil {
 		return worker.RuntimeErrorExit, fmt.Errorf("failed to parse raw config: %w", err)
 	}
+	w.config.Store(&config)
+	defer w.config.Store(nil)
 
-	inputChannel, err := services.CreateMailbox(config.InputMailboxUUID, config.InputMailboxBuffer)
-	defer services.RemoveMailbox(config.InputMailboxUUID)
-	if err != nil {
-		return worker.RuntimeErrorExit, fmt.Errorf("failed to create input mailbox: %w", err)
-	}
-
-	for {
-		select {
-		case <-ctx.Done():
-			return worker.NormalExit, nil
-		case rawMessage, ok := <-inputChannel:
-			// Ensure the main input channel is open.
-			if !ok {
-				return worker.PrematureExit, fmt.Errorf("main input channel closed")
-			}
-
-			// Cast the incoming message.
-			message, ok := rawMessage.(worker.Message)
-			if !ok {
-				return worker.RuntimeErrorExit, fmt.Errorf("message is not of type worker.Message")
-			}
-
-			// Find the output destination based on the input message tag.
-			mappedOutput, ok := config.InputOutputMapping[message.Tag]
-			if !ok {
-				return worker.RuntimeErrorExit, fmt.Errorf("destination mapping not found for tag: %s", message.Tag)
-			}
-
-			// Cast the payload to OrderBookSnapshot.
-			snapshot, ok := message.Payload.(models.OrderBook)
-			if !ok {
-				return worker.RuntimeErrorExit, fmt.Errorf("message payload is not of type models.OrderBookSnapshot")
-			}
-
-			// Filter the snapshot within the specified n% range about the mid-price.
-			filteredSnapshot, err := filterSnapshot(snapshot, config.RangePercentage)
-			if err != nil {
-				return worker.RuntimeErrorExit, fmt.Errorf("failed to filter snapshot: %w", err)
-			}
-
-			// Send the filtered snapshot.
-			err = services.SendMessage(mappedOutput.MailboxUUID, worker.Message{
-				Tag:     mappedOutput.Tag,
-				Payload: filteredSnapshot,
-			}, config.BlockingSend)
-			if err != nil {
-				return worker.RuntimeErrorExit, fmt.Errorf("failed to send filtered snapshot: %w", err)
-			}
-		}
-	}
+	// Filter each snapshot within the n% range about the mid-price, under the current config.
+	return w.Transformer.Run(ctx, rawConfig, services, func(snapshot models.OrderBook) (models.OrderBook, error) {
+		return filterSnapshot(snapshot, w.config.Load().RangePercentage)
+	})
 }
 
-func (w *OrderBookRangeFilterWorker) parseRawConfig(rawConfig any) (OrderBookRangeFilterConfig, error) {
-	configBytes, ok := rawConfig.([]byte)
-	if !ok {
-		return OrderBookRangeFilterConfig{}, fmt.Errorf("config is not in the expected []byte format")
-	}
-
-	var config OrderBookRangeFilterConfig
-	if err := yaml.Unmarshal(configBytes, &config); err != nil {
-		return OrderBookRangeFilterConfig{}, fmt.Errorf("failed to unmarshal configuration: %w", err)
-	}
-
-	if config.InputMailboxUUID == uuid.Nil {
-		return OrderBookRangeFilterConfig{}, fmt.Errorf("input_mailbox_uuid is required in configuration")
+// Reconfigure swaps the mappings, send mode, policies and range of the running worker. Changes to the input mailbox
+// require a restart.
+func (w *OrderBookRangeFilterWorker) Reconfigure(rawConfig any) error {
+	config, err := w.parseRawConfig(rawConfig)
+	if err != nil {
+		return err
 	}
-
-	if len(config.InputOutputMapping) == 0 {
-		return OrderBookRangeFilterConfig{}, fmt.Errorf("input_output_mapping is required in configuration")
+	if err := w.Transformer.Reconfigure(rawConfig); err != nil {
+		return err
 	}
 
-	for tag, mapping := range config.InputOutputMapping {
-		if mapping.MailboxUUID == uuid.Nil {
-			return OrderBookRangeFilterConfig{}, fmt.Errorf("mailbox_uuid is required for tag: %s", tag)
-		}
-	}
+	w.config.Store(&config)
+	return nil
+}
 
-	// Ensure a valid range percentage.
-	if config.RangePercentage <= 0 {
-		return OrderBookRangeFilterConfig{}, fmt.Errorf("range_percentage must be greater than 0")
+// InspectConfig validates the raw config and reports the mailboxes the worker creates and sends to.
+func (w *OrderBookRangeFilterWorker) InspectConfig(rawConfig any) (worker.ConfigInfo, error) {
+	config, err := w.parseRawConfig(rawConfig)
+	if err != nil {
+		return worker.ConfigInfo{}, err
 	}
+	return config.Info(), nil
+}
 
-	return config, nil
+func (w *OrderBookRangeFilterWorker) parseRawConfig(rawConfig any) (OrderBookRangeFilterConfig, error) {
+	return config.Decode[OrderBookRangeFilterConfig](rawConfig)
 }
 
 // filterSnapshot computes the mid-price from the best ask and bid, and returns a snapshot containing only the
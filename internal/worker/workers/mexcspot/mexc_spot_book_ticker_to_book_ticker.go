@@ -8,101 +8,21 @@ import (
 	"github.com/PhillipMichelsen/Tessera/internal/worker"
 	"strconv"
 	"time"
-
-	"github.com/google/uuid"
-	"gopkg.in/yaml.v3"
 )
 
 // MEXCSpotBookTickerToBookTickerConfig represents the YAML configuration for the worker.
 type MEXCSpotBookTickerToBookTickerConfig struct {
-	InputMailboxUUID   uuid.UUID `yaml:"input_mailbox_uuid"`
-	InputMailboxBuffer int       `yaml:"input_mailbox_buffer"`
-	InputOutputMapping map[string]struct {
-		MailboxUUID uuid.UUID `yaml:"mailbox_uuid"`
-		Tag         string    `yaml:"tag"`
-	} `yaml:"input_output_mapping"`
-	BlockingSend bool `yaml:"blocking_send"`
+	worker.TransformerConfig `yaml:",inline"`
 }
 
 // MEXCSpotBookTickerToBookTickerWorker implements the worker.Worker interface.
-type MEXCSpotBookTickerToBookTickerWorker struct{}
+type MEXCSpotBookTickerToBookTickerWorker struct {
+	worker.Transformer[*protos.PushDataV3ApiWrapper, models.BookTicker]
+}
 
 // Run listens for incoming messages, converts the payload from protobuf to an internal BookTicker, and sends it onward.
 func (w *MEXCSpotBookTickerToBookTickerWorker) Run(ctx context.Context, rawConfig any, services worker.Services) (worker.ExitCode, error) {
-	config, err := w.parseRawConfig(rawConfig)
-	if err != nil {
-		return worker.RuntimeErrorExit, fmt.Errorf("failed to parse raw config: %w", err)
-	}
-
-	inputChannel, err := services.CreateMailbox(config.InputMailboxUUID, config.InputMailboxBuffer)
-	defer services.RemoveMailbox(config.InputMailboxUUID)
-	if err != nil {
-		return worker.RuntimeErrorExit, fmt.Errorf("failed to create input mailbox: %w", err)
-	}
-
-	for {
-		select {
-		case <-ctx.Done():
-			return worker.NormalExit, nil
-		case rawMessage, ok := <-inputChannel:
-			if !ok {
-				return worker.PrematureExit, fmt.Errorf("input mailbox channel closed")
-			}
-
-			message, ok := rawMessage.(worker.Message)
-			if !ok {
-				return worker.RuntimeErrorExit, fmt.Errorf("message is not of type worker.Message")
-			}
-
-			mappedOutput, ok := config.InputOutputMapping[message.Tag]
-			if !ok {
-				return worker.RuntimeErrorExit, fmt.Errorf("destination mapping not found for tag: %s", message.Tag)
-			}
-
-			// cast message.Payload to protos.PushDataV3ApiWrapper
-			// call parseMEXCProtobufPushBodyToBookTicker
-			pushData := message.Payload.(*protos.PushDataV3ApiWrapper)
-			bookTicker, err := w.parseMEXCProtobufPushBodyToBookTicker(pushData)
-			if err != nil {
-				return worker.RuntimeErrorExit, fmt.Errorf("failed to parse protobuf to BookTicker: %w", err)
-			}
-
-			if err := services.SendMessage(mappedOutput.MailboxUUID, worker.Message{
-				Tag:     mappedOutput.Tag,
-				Payload: bookTicker,
-			}, config.BlockingSend); err != nil {
-				return worker.RuntimeErrorExit, fmt.Errorf("failed to send message: %w", err)
-			}
-		}
-	}
-}
-
-// parseRawConfig unmarshals the YAML configuration.
-func (w *MEXCSpotBookTickerToBookTickerWorker) parseRawConfig(rawConfig any) (MEXCSpotBookTickerToBookTickerConfig, error) {
-	configBytes, ok := rawConfig.([]byte)
-	if !ok {
-		return MEXCSpotBookTickerToBookTickerConfig{}, fmt.Errorf("config is not in expected []byte format")
-	}
-
-	var config MEXCSpotBookTickerToBookTickerConfig
-	if err := yaml.Unmarshal(configBytes, &config); err != nil {
-		return MEXCSpotBookTickerToBookTickerConfig{}, fmt.Errorf("failed to unmarshal configuration: %w", err)
-	}
-
-	if config.InputMailboxUUID == uuid.Nil {
-		return MEXCSpotBookTickerToBookTickerConfig{}, fmt.Errorf("input_mailbox_uuid is required in configuration")
-	}
-	if len(config.InputOutputMapping) == 0 {
-		return MEXCSpotBookTickerToBookTickerConfig{}, fmt.Errorf("input_output_mapping is required in configuration")
-	}
-
-	for tag, mapping := range config.InputOutputMapping {
-		if mapping.MailboxUUID == uuid.Nil {
-			return MEXCSpotBookTickerToBookTickerConfig{}, fmt.Errorf("mailbox_uuid is required for tag: %s", tag)
-		}
-	}
-
-	return config, nil
+	return w.Transformer.Run(ctx, rawConfig, services, w.parseMEXCProtobufPushBodyToBookTicker)
 }
 
 // parseMEXCProtobufPushBodyToBookTicker unmarshals the payload into a protobuf message,
@@ -5,26 +5,33 @@ import (
 	"fmt"
 	protos "github.com/PhillipMichelsen/Tessera/internal/protos/mexc"
 	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/PhillipMichelsen/Tessera/internal/worker/config"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"google.golang.org/protobuf/proto"
-	"gopkg.in/yaml.v3"
 	"net/url"
+	"time"
 )
 
 // MEXCSpotWebsocketWorkerConfig defines the YAML configuration.
 type MEXCSpotWebsocketWorkerConfig struct {
-	BaseURL              string `yaml:"base_url"`
+	BaseURL              string `yaml:"base_url" validate:"required"`
 	StreamsOutputMapping map[string]struct {
-		MailboxUUID uuid.UUID `yaml:"mailbox_uuid"`
+		MailboxUUID uuid.UUID `yaml:"mailbox_uuid" validate:"required"`
 		Tag         string    `yaml:"tag"`
-	} `yaml:"streams_output_mapping"`
+	} `yaml:"streams_output_mapping" validate:"required"`
 	BlockingSend bool `yaml:"blocking_send"`
 }
 
 // MEXCSpotWebsocketWorker implements the worker.Worker interface.
 type MEXCSpotWebsocketWorker struct{}
 
+// websocketRead is a frame read from the websocket and when it was read.
+type websocketRead struct {
+	data   []byte
+	readAt time.Time
+}
+
 func (w *MEXCSpotWebsocketWorker) Run(ctx context.Context, rawConfig any, services worker.Services) (worker.ExitCode, error) {
 	cfg, err := w.parseRawConfig(rawConfig)
 	if err != nil {
@@ -58,9 +65,10 @@ func (w *MEXCSpotWebsocketWorker) Run(ctx context.Context, rawConfig any, servic
 	if err = conn.WriteJSON(subscribeMsg); err != nil {
 		return worker.RuntimeErrorExit, fmt.Errorf("failed to send subscription message: %w", err)
 	}
+	services.Ready()
 
 	// Create channels for messages and errors.
-	msgCh := make(chan []byte)
+	msgCh := make(chan websocketRead)
 	errCh := make(chan error)
 
 	// Launch a goroutine that reads from the websocket.
@@ -71,7 +79,7 @@ func (w *MEXCSpotWebsocketWorker) Run(ctx context.Context, rawConfig any, servic
 				errCh <- err
 				return
 			}
-			msgCh <- message
+			msgCh <- websocketRead{data: message, readAt: time.Now()}
 		}
 	}()
 
@@ -81,7 +89,8 @@ func (w *MEXCSpotWebsocketWorker) Run(ctx context.Context, rawConfig any, servic
 			return worker.NormalExit, nil
 		case err := <-errCh:
 			return worker.RuntimeErrorExit, fmt.Errorf("failed to read message: %w", err)
-		case message := <-msgCh:
+		case read := <-msgCh:
+			message := read.data
 			// Catch the subscription response, which for some reason is sent as a JSON object.
 			if message[0] == '{' {
 				fmt.Printf("Received subscription response: %+v\n", string(message))
@@ -100,8 +109,9 @@ func (w *MEXCSpotWebsocketWorker) Run(ctx context.Context, rawConfig any, servic
 			}
 
 			if err := services.SendMessage(output.MailboxUUID, worker.Message{
-				Tag:     output.Tag,
-				Payload: &msg,
+				Tag:       output.Tag,
+				Payload:   &msg,
+				CreatedAt: read.readAt,
 			}, cfg.BlockingSend); err != nil {
 				return worker.RuntimeErrorExit, fmt.Errorf("failed to send message: %w", err)
 			}
@@ -110,24 +120,21 @@ func (w *MEXCSpotWebsocketWorker) Run(ctx context.Context, rawConfig any, servic
 
 }
 
-// parseRawConfig converts the raw YAML configuration into MEXCSpotWebsocketWorkerConfig.
-func (w *MEXCSpotWebsocketWorker) parseRawConfig(rawConfig any) (MEXCSpotWebsocketWorkerConfig, error) {
-	configBytes, ok := rawConfig.([]byte)
-	if !ok {
-		return MEXCSpotWebsocketWorkerConfig{}, fmt.Errorf("config is not in the expected []byte format")
-	}
-
-	var config MEXCSpotWebsocketWorkerConfig
-	if err := yaml.Unmarshal(configBytes, &config); err != nil {
-		return MEXCSpotWebsocketWorkerConfig{}, fmt.Errorf("failed to unmarshal configuration: %w", err)
+// InspectConfig validates the raw config and reports the mailboxes the worker creates and sends to.
+func (w *MEXCSpotWebsocketWorker) InspectConfig(rawConfig any) (worker.ConfigInfo, error) {
+	config, err := w.parseRawConfig(rawConfig)
+	if err != nil {
+		return worker.ConfigInfo{}, err
 	}
 
-	if config.BaseURL == "" {
-		return MEXCSpotWebsocketWorkerConfig{}, fmt.Errorf("base_url is required in configuration")
-	}
-	if len(config.StreamsOutputMapping) == 0 {
-		return MEXCSpotWebsocketWorkerConfig{}, fmt.Errorf("at least one stream must be provided in configuration")
+	var info worker.ConfigInfo
+	for _, mapping := range config.StreamsOutputMapping {
+		info.OutputMailboxes = append(info.OutputMailboxes, mapping.MailboxUUID)
 	}
+	return info, nil
+}
 
-	return config, nil
+// parseRawConfig converts the raw YAML configuration into MEXCSpotWebsocketWorkerConfig.
+func (w *MEXCSpotWebsocketWorker) parseRawConfig(rawConfig any) (MEXCSpotWebsocketWorkerConfig, error) {
+	return config.Decode[MEXCSpotWebsocketWorkerConfig](rawConfig)
 }
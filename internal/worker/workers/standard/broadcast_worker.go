@@ -2,86 +2,146 @@ package workers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync/atomic"
 
 	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/PhillipMichelsen/Tessera/internal/worker/config"
 	"github.com/google/uuid"
-	"gopkg.in/yaml.v3"
 )
 
-// BroadcastWorkerConfig defines the YAML configuration for mapping input tags to destination mailbox UUIDs.
+// BroadcastWorkerConfig defines the YAML configuration for mapping input tags to destination mailbox UUIDs and topics.
 type BroadcastWorkerConfig struct {
-	InputMailboxUUID   uuid.UUID              `yaml:"input_mailbox_uuid"`
-	InputMailboxBuffer int                    `yaml:"input_mailbox_buffer"`
-	TagDestinations    map[string][]uuid.UUID `yaml:"tag_destinations"`
-	BlockingSend       bool                   `yaml:"blocking_send"`
+	InputMailboxUUID     uuid.UUID              `yaml:"input_mailbox_uuid" validate:"required"`
+	InputMailboxBuffer   int                    `yaml:"input_mailbox_buffer" validate:"min=0"`
+	InputMailboxOverflow worker.OverflowConfig  `yaml:"input_mailbox_overflow"`
+	InputMailboxBackend  worker.MailboxBackend  `yaml:"input_mailbox_backend"`
+	TagDestinations      map[string][]uuid.UUID `yaml:"tag_destinations"`
+	TagTopics            map[string]string      `yaml:"tag_topics"`
+	BlockingSend         bool                   `yaml:"blocking_send"`
+	// UnmappedTagPolicy applies to messages whose tag is mapped to neither destinations nor a topic.
+	UnmappedTagPolicy worker.MessagePolicy `yaml:"unmapped_tag_policy" default:"dead-letter"`
+}
+
+// Validate checks the input mailbox options, the unmapped tag policy and that at least one tag is mapped to
+// destinations or a topic.
+func (c BroadcastWorkerConfig) Validate() error {
+	if err := worker.NewMailboxOptions(c.inputMailboxOptions()...).Validate(); err != nil {
+		return fmt.Errorf("invalid input mailbox: %w", err)
+	}
+	if err := c.UnmappedTagPolicy.Validate(); err != nil {
+		return fmt.Errorf("invalid unmapped_tag_policy: %w", err)
+	}
+	if len(c.TagDestinations) == 0 && len(c.TagTopics) == 0 {
+		return fmt.Errorf("at least one tag mapping must be provided in configuration")
+	}
+	return nil
+}
+
+func (c BroadcastWorkerConfig) inputMailboxOptions() []worker.MailboxOption {
+	return worker.MailboxConfigOptions(c.InputMailboxOverflow, c.InputMailboxBackend)
 }
 
 // BroadcastWorker takes a message from an input mailbox and broadcasts it to multiple destination mailboxes based on the message's tag.
-type BroadcastWorker struct{}
+// Tags mapped to a topic are also published to it, reaching whichever mailboxes are subscribed at the time.
+// Messages with an unmapped tag are handled by the unmapped tag policy. Its mappings, send mode and policy can be
+// reconfigured while it runs.
+type BroadcastWorker struct {
+	config atomic.Pointer[BroadcastWorkerConfig]
+}
 
 func (w *BroadcastWorker) Run(ctx context.Context, rawConfig any, services worker.Services) (worker.ExitCode, error) {
 	config, err := w.parseConfig(rawConfig)
 	if err != nil {
 		return worker.RuntimeErrorExit, fmt.Errorf("failed to parse config: %w", err)
 	}
+	w.config.Store(&config)
+	defer w.config.Store(nil)
 
-	inputChannel, err := services.CreateMailbox(config.InputMailboxUUID, config.InputMailboxBuffer)
+	inbox, err := services.CreateInbox(config.InputMailboxUUID, config.InputMailboxBuffer, config.inputMailboxOptions()...)
 	defer services.RemoveMailbox(config.InputMailboxUUID)
 	if err != nil {
 		return worker.RuntimeErrorExit, fmt.Errorf("failed to create input mailbox: %w", err)
 	}
+	services.Ready()
 
 	// Process messages from the input mailbox.
 	for {
-		select {
-		case <-ctx.Done():
-			return worker.NormalExit, nil
-		case msg, ok := <-inputChannel:
-			if !ok {
-				return worker.PrematureExit, fmt.Errorf("input mailbox channel closed")
+		m, err := inbox.Receive(ctx)
+		if err != nil {
+			switch {
+			case ctx.Err() != nil:
+				return worker.NormalExit, nil
+			case errors.Is(err, worker.ErrInboxClosed):
+				return worker.PrematureExit, fmt.Errorf("input mailbox closed")
+			default:
+				return worker.RuntimeErrorExit, fmt.Errorf("failed to receive message: %w", err)
 			}
+		}
 
-			// Ensure the message is of the expected type.
-			m, ok := msg.(worker.Message)
-			if !ok {
-				return worker.RuntimeErrorExit, fmt.Errorf("unexpected message type: %T", msg)
+		// Lookup the destinations for the message's tag under the current config.
+		current := w.config.Load()
+		destinations, exists := current.TagDestinations[m.Tag]
+		topic, published := current.TagTopics[m.Tag]
+		if !exists && !published {
+			reason := fmt.Errorf("no destinations found for tag: %s", m.Tag)
+			if err := current.UnmappedTagPolicy.Handle(services, config.InputMailboxUUID, m, reason); err != nil {
+				return worker.RuntimeErrorExit, err
 			}
+			continue
+		}
 
-			// Lookup the destinations for the message's tag.
-			destinations, exists := config.TagDestinations[m.Tag]
-			if !exists {
-				return worker.RuntimeErrorExit, fmt.Errorf("no destinations found for tag: %s", m.Tag)
+		// Broadcast the message with the same tag to all destination mailboxes.
+		for _, dest := range destinations {
+			if err := services.SendMessage(dest, m, current.BlockingSend); err != nil {
+				return worker.RuntimeErrorExit, fmt.Errorf("failed to send message to %s: %w", dest, err)
 			}
-
-			// Broadcast the message with the same tag to all destination mailboxes.
-			for _, dest := range destinations {
-				if err := services.SendMessage(dest, m, config.BlockingSend); err != nil {
-					return worker.RuntimeErrorExit, fmt.Errorf("failed to send message to %s: %w", dest, err)
-				}
+		}
+		if published {
+			if err := services.Publish(topic, m, current.BlockingSend); err != nil {
+				return worker.RuntimeErrorExit, fmt.Errorf("failed to publish message to topic %q: %w", topic, err)
 			}
 		}
 	}
 }
 
-// parseConfig unmarshals and validates the worker configuration.
-func (w *BroadcastWorker) parseConfig(rawConfig any) (BroadcastWorkerConfig, error) {
-	configBytes, ok := rawConfig.([]byte)
-	if !ok {
-		return BroadcastWorkerConfig{}, fmt.Errorf("config is not in the expected []byte format")
+// Reconfigure swaps the tag mappings, send mode and unmapped tag policy of the running worker. Changes to the input mailbox require a
+// restart.
+func (w *BroadcastWorker) Reconfigure(rawConfig any) error {
+	config, err := w.parseConfig(rawConfig)
+	if err != nil {
+		return err
 	}
 
-	var config BroadcastWorkerConfig
-	if err := yaml.Unmarshal(configBytes, &config); err != nil {
-		return BroadcastWorkerConfig{}, fmt.Errorf("failed to unmarshal configuration: %w", err)
+	current := w.config.Load()
+	if current == nil {
+		return fmt.Errorf("worker is not running: %w", worker.ErrRestartRequired)
 	}
+	if config.InputMailboxUUID != current.InputMailboxUUID || config.InputMailboxBuffer != current.InputMailboxBuffer ||
+		config.InputMailboxOverflow != current.InputMailboxOverflow || config.InputMailboxBackend != current.InputMailboxBackend {
+		return fmt.Errorf("input mailbox changed: %w", worker.ErrRestartRequired)
+	}
+
+	w.config.Store(&config)
+	return nil
+}
 
-	if config.InputMailboxUUID == uuid.Nil {
-		return BroadcastWorkerConfig{}, fmt.Errorf("input_mailbox_uuid is required")
+// InspectConfig validates the raw config and reports the mailboxes the worker creates and sends to.
+func (w *BroadcastWorker) InspectConfig(rawConfig any) (worker.ConfigInfo, error) {
+	config, err := w.parseConfig(rawConfig)
+	if err != nil {
+		return worker.ConfigInfo{}, err
 	}
-	if len(config.TagDestinations) == 0 {
-		return BroadcastWorkerConfig{}, fmt.Errorf("at least one tag mapping must be provided in configuration")
+
+	info := worker.ConfigInfo{InputMailboxes: []uuid.UUID{config.InputMailboxUUID}}
+	for _, destinations := range config.TagDestinations {
+		info.OutputMailboxes = append(info.OutputMailboxes, destinations...)
 	}
+	return info, nil
+}
 
-	return config, nil
+// parseConfig unmarshals and validates the worker configuration.
+func (w *BroadcastWorker) parseConfig(rawConfig any) (BroadcastWorkerConfig, error) {
+	return config.Decode[BroadcastWorkerConfig](rawConfig)
 }
@@ -4,21 +4,23 @@ import (
 	"context"
 	"fmt"
 	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/PhillipMichelsen/Tessera/internal/worker/config"
 	"github.com/google/uuid"
-	"gopkg.in/yaml.v3"
 )
 
 // StandardOutputConfig represents the YAML configuration for the StandardOutputWorker.
 type StandardOutputConfig struct {
-	InputMailboxUUID   uuid.UUID `yaml:"input_mailbox_uuid"`
-	InputMailboxBuffer int       `yaml:"input_mailbox_buffer"`
+	InputMailboxUUID     uuid.UUID             `yaml:"input_mailbox_uuid" validate:"required"`
+	InputMailboxBuffer   int                   `yaml:"input_mailbox_buffer" validate:"min=0"`
+	InputMailboxOverflow worker.OverflowConfig `yaml:"input_mailbox_overflow"`
+	SubscribeTopics      []string              `yaml:"subscribe_topics"`
 }
 
 // StandardOutputWorker implements the worker.Worker interface.
 // It simply prints any received message to standard output.
 type StandardOutputWorker struct{}
 
-// Run initializes the mailbox using the mailbox_uuid from configuration,
+// Run initializes the mailbox using the mailbox_uuid from configuration, subscribes it to the configured topics,
 // then continuously prints any received message to stdout.
 func (w *StandardOutputWorker) Run(ctx context.Context, rawConfig any, services worker.Services) (worker.ExitCode, error) {
 	config, err := w.parseRawConfig(rawConfig)
@@ -26,12 +28,19 @@ func (w *StandardOutputWorker) Run(ctx context.Context, rawConfig any, services
 		return worker.RuntimeErrorExit, fmt.Errorf("failed to parse raw config: %w", err)
 	}
 
-	inputChannel, err := services.CreateMailbox(config.InputMailboxUUID, config.InputMailboxBuffer)
+	inputChannel, err := services.CreateMailbox(config.InputMailboxUUID, config.InputMailboxBuffer, config.InputMailboxOverflow.Options()...)
 	defer services.RemoveMailbox(config.InputMailboxUUID)
 	if err != nil {
 		return worker.RuntimeErrorExit, fmt.Errorf("failed to create input mailbox: %w", err)
 	}
 
+	for _, topic := range config.SubscribeTopics {
+		if err := services.Subscribe(topic, config.InputMailboxUUID); err != nil {
+			return worker.RuntimeErrorExit, fmt.Errorf("failed to subscribe to topic %q: %w", topic, err)
+		}
+	}
+	services.Ready()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -44,20 +53,16 @@ func (w *StandardOutputWorker) Run(ctx context.Context, rawConfig any, services
 	}
 }
 
-func (w *StandardOutputWorker) parseRawConfig(rawConfig any) (StandardOutputConfig, error) {
-	configBytes, ok := rawConfig.([]byte)
-	if !ok {
-		return StandardOutputConfig{}, fmt.Errorf("config is not in the expected []byte format")
-	}
-
-	var config StandardOutputConfig
-	if err := yaml.Unmarshal(configBytes, &config); err != nil {
-		return StandardOutputConfig{}, fmt.Errorf("failed to unmarshal configuration: %w", err)
+// InspectConfig validates the raw config and reports the mailboxes the worker creates and sends to.
+func (w *StandardOutputWorker) InspectConfig(rawConfig any) (worker.ConfigInfo, error) {
+	config, err := w.parseRawConfig(rawConfig)
+	if err != nil {
+		return worker.ConfigInfo{}, err
 	}
 
-	if config.InputMailboxUUID == uuid.Nil {
-		return StandardOutputConfig{}, fmt.Errorf("mailbox_uuid is nil")
-	}
+	return worker.ConfigInfo{InputMailboxes: []uuid.UUID{config.InputMailboxUUID}}, nil
+}
 
-	return config, nil
+func (w *StandardOutputWorker) parseRawConfig(rawConfig any) (StandardOutputConfig, error) {
+	return config.Decode[StandardOutputConfig](rawConfig)
 }
@@ -5,18 +5,19 @@ import (
 	"fmt"
 	"github.com/PhillipMichelsen/Tessera/internal/models"
 	"github.com/PhillipMichelsen/Tessera/internal/worker"
+	"github.com/PhillipMichelsen/Tessera/internal/worker/config"
 	"github.com/google/uuid"
-	"gopkg.in/yaml.v3"
 )
 
 // CrossMarketSpotArbitrageStrategyConfig represents the YAML configuration for the worker.
 type CrossMarketSpotArbitrageStrategyConfig struct {
-	Market1BookTickerMailboxUUID uuid.UUID `yaml:"market_1_book_ticker_mailbox_uuid"`
-	Market2BookTickerMailboxUUID uuid.UUID `yaml:"market_2_book_ticker_mailbox_uuid"`
-	MailboxBuffers               int       `yaml:"mailbox_buffers"`
+	Market1BookTickerMailboxUUID uuid.UUID             `yaml:"market_1_book_ticker_mailbox_uuid" validate:"required"`
+	Market2BookTickerMailboxUUID uuid.UUID             `yaml:"market_2_book_ticker_mailbox_uuid" validate:"required"`
+	MailboxBuffers               int                   `yaml:"mailbox_buffers" validate:"min=0"`
+	MailboxOverflow              worker.OverflowConfig `yaml:"mailbox_overflow"`
 	Output                       struct {
-		MailboxUUID uuid.UUID `yaml:"mailbox_uuid"`
-		Tag         string    `yaml:"tag"`
+		MailboxUUID uuid.UUID `yaml:"mailbox_uuid" validate:"required"`
+		Tag         string    `yaml:"tag" validate:"required"`
 	} `yaml:"output"`
 	BlockingSend bool `yaml:"blocking_send"`
 }
@@ -34,17 +35,18 @@ func (w *CrossMarketSpotArbitrageStrategyWorker) Run(ctx context.Context, rawCon
 	}
 
 	// Create mailboxes for each market.
-	market1Channel, err := services.CreateMailbox(config.Market1BookTickerMailboxUUID, config.MailboxBuffers)
+	market1Channel, err := services.CreateMailbox(config.Market1BookTickerMailboxUUID, config.MailboxBuffers, config.MailboxOverflow.Options()...)
 	defer services.RemoveMailbox(config.Market1BookTickerMailboxUUID)
 	if err != nil {
 		return worker.RuntimeErrorExit, fmt.Errorf("failed to create market 1 mailbox: %w", err)
 	}
 
-	market2Channel, err := services.CreateMailbox(config.Market2BookTickerMailboxUUID, config.MailboxBuffers)
+	market2Channel, err := services.CreateMailbox(config.Market2BookTickerMailboxUUID, config.MailboxBuffers, config.MailboxOverflow.Options()...)
 	defer services.RemoveMailbox(config.Market2BookTickerMailboxUUID)
 	if err != nil {
 		return worker.RuntimeErrorExit, fmt.Errorf("failed to create market 2 mailbox: %w", err)
 	}
+	services.Ready()
 
 	// Main loop: listen for updates from both channels.
 	for {
@@ -61,7 +63,7 @@ func (w *CrossMarketSpotArbitrageStrategyWorker) Run(ctx context.Context, rawCon
 			}
 
 			w.market1LastBookTicker = bookTicker
-			w.compareAndSend(services, config)
+			w.compareAndSend(services, config, message)
 		case msg := <-market2Channel:
 			message, ok := msg.(worker.Message)
 			if !ok {
@@ -74,15 +76,16 @@ func (w *CrossMarketSpotArbitrageStrategyWorker) Run(ctx context.Context, rawCon
 			}
 
 			w.market2LastBookTicker = bookTicker
-			w.compareAndSend(services, config)
+			w.compareAndSend(services, config, message)
 		case <-ctx.Done():
 			return worker.NormalExit, nil
 		}
 	}
 }
 
-// compareAndSend computes the arbitrage differences and sends a message if an opportunity exists.
-func (w *CrossMarketSpotArbitrageStrategyWorker) compareAndSend(services worker.Services, config CrossMarketSpotArbitrageStrategyConfig) {
+// compareAndSend computes the arbitrage differences and sends a message if an opportunity exists. The message
+// continues the trace of the ticker update that triggered it.
+func (w *CrossMarketSpotArbitrageStrategyWorker) compareAndSend(services worker.Services, config CrossMarketSpotArbitrageStrategyConfig, trigger worker.Message) {
 	// Check if both tickers have been updated.
 	if w.market1LastBookTicker.AskPrice == 0 || w.market2LastBookTicker.AskPrice == 0 {
 		return
@@ -107,41 +110,25 @@ func (w *CrossMarketSpotArbitrageStrategyWorker) compareAndSend(services worker.
 	}
 
 	// Send the message to the output mailbox.
-	err := services.SendMessage(config.Output.MailboxUUID, worker.Message{
-		Tag:     config.Output.Tag,
-		Payload: message,
-	}, config.BlockingSend)
+	err := services.SendMessage(config.Output.MailboxUUID, trigger.Continue(config.Output.Tag, message), config.BlockingSend)
 	if err != nil {
 		fmt.Printf("failed to send arbitrage message: %v\n", err)
 	}
 }
 
-func (w *CrossMarketSpotArbitrageStrategyWorker) parseRawConfig(rawConfig any) (CrossMarketSpotArbitrageStrategyConfig, error) {
-	configBytes, ok := rawConfig.([]byte)
-	if !ok {
-		return CrossMarketSpotArbitrageStrategyConfig{}, fmt.Errorf("config is not in the expected []byte format")
-	}
-
-	var config CrossMarketSpotArbitrageStrategyConfig
-	if err := yaml.Unmarshal(configBytes, &config); err != nil {
-		return CrossMarketSpotArbitrageStrategyConfig{}, fmt.Errorf("failed to unmarshal configuration: %w", err)
-	}
-
-	if config.Market1BookTickerMailboxUUID == uuid.Nil {
-		return CrossMarketSpotArbitrageStrategyConfig{}, fmt.Errorf("market_1_book_ticker_mailbox_uuid is required in configuration")
-	}
-
-	if config.Market2BookTickerMailboxUUID == uuid.Nil {
-		return CrossMarketSpotArbitrageStrategyConfig{}, fmt.Errorf("market_2_book_ticker_mailbox_uuid is required in configuration")
-	}
-
-	if config.Output.MailboxUUID == uuid.Nil {
-		return CrossMarketSpotArbitrageStrategyConfig{}, fmt.Errorf("output_mailbox_uuid is required in configuration")
+// InspectConfig validates the raw config and reports the mailboxes the worker creates and sends to.
+func (w *CrossMarketSpotArbitrageStrategyWorker) InspectConfig(rawConfig any) (worker.ConfigInfo, error) {
+	config, err := w.parseRawConfig(rawConfig)
+	if err != nil {
+		return worker.ConfigInfo{}, err
 	}
 
-	if config.Output.Tag == "" {
-		return CrossMarketSpotArbitrageStrategyConfig{}, fmt.Errorf("output_tag is required in configuration")
-	}
+	return worker.ConfigInfo{
+		InputMailboxes:  []uuid.UUID{config.Market1BookTickerMailboxUUID, config.Market2BookTickerMailboxUUID},
+		OutputMailboxes: []uuid.UUID{config.Output.MailboxUUID},
+	}, nil
+}
 
-	return config, nil
+func (w *CrossMarketSpotArbitrageStrategyWorker) parseRawConfig(rawConfig any) (CrossMarketSpotArbitrageStrategyConfig, error) {
+	return config.Decode[CrossMarketSpotArbitrageStrategyConfig](rawConfig)
 }